@@ -0,0 +1,144 @@
+// Defaults for the flags and named presets, read from the config file
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const configVersion = 1 // 版本, of configFile
+
+// 配置文件, options are flags without the -, a preset sets more of them
+// 格式为
+//
+//	{
+//	  "version": 1,
+//	  "options": {
+//	    "theme": "default",
+//	    "showwpm": true
+//	  },
+//	  "presets": {
+//	    "sprint": {"time": 15, "words": "1k"},
+//	    "code": {"code": "main.go", "typeindent": true}
+//	  }
+//	}
+type configFile struct {
+	Version int                                   `json:"version"`
+	Options map[string]json.RawMessage            `json:"options"` // defaults for every run, "preset" picks a default preset
+	Presets map[string]map[string]json.RawMessage `json:"presets"` // 预设, picked with -preset
+}
+
+func configPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// loadConfig reads the config file. A missing file is an empty config.
+func loadConfig() (configFile, error) {
+	var config configFile
+
+	path, err := configPath()
+	if err != nil {
+		return config, err
+	}
+
+	err = readJSONFile(path, "config", configVersion, &config)
+	if os.IsNotExist(err) {
+		return configFile{}, nil
+	}
+	return config, err
+}
+
+// optionValue is the value of an option as it would be given on the command
+// line. Strings are taken as they are, other values as their json text, so
+// "numwords": 25 and "numwords": "25" are the same.
+func optionValue(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// setOptions sets the flags of options which weren't given on the command
+// line.
+func setOptions(flags *flag.FlagSet, options map[string]json.RawMessage, given map[string]bool, where string) error {
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("%s: there is no -%s option", where, name)
+		}
+		if given[name] {
+			continue
+		}
+
+		if err := flags.Set(name, optionValue(options[name])); err != nil {
+			return fmt.Errorf("%s: %s: %s", where, name, err)
+		}
+	}
+	return nil
+}
+
+// apply sets the flags which weren't given on the command line from the
+// options of the config, then from the preset, the preset winning over the
+// options and the command line over both. An empty preset is the one named
+// by the options, if any.
+func (c configFile) apply(flags *flag.FlagSet, preset string) error {
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	given["preset"] = true // taken from the command line or the options, not set by them
+	if preset == "" {
+		if raw, ok := c.Options["preset"]; ok && json.Unmarshal(raw, &preset) != nil {
+			return fmt.Errorf("options: preset must be the name of a preset")
+		}
+	}
+
+	if preset != "" {
+		options, ok := c.Presets[preset]
+		if !ok {
+			return fmt.Errorf("there is no %s preset, use -list presets to see the presets", preset)
+		}
+		if err := setOptions(flags, options, given, "presets."+preset); err != nil {
+			return err
+		}
+
+		// The preset wins over the options
+		flags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	}
+
+	return setOptions(flags, c.Options, given, "options")
+}
+
+// printPresets lists the presets of the config with their options
+func printPresets(c configFile) {
+	names := make([]string, 0, len(c.Presets))
+	for name := range c.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var options []string
+		for option, value := range c.Presets[name] {
+			options = append(options, fmt.Sprintf("-%s %s", option, optionValue(value)))
+		}
+		sort.Strings(options)
+		fmt.Printf("%-10s %s\n", name, strings.Join(options, " "))
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestConfigApply(t *testing.T) {
+	var config configFile
+	err := json.Unmarshal([]byte(`{
+		"version": 1,
+		"options": {"theme": "dark", "numwords": 25, "showwpm": true},
+		"presets": {
+			"sprint": {"time": 15, "numwords": "10"},
+			"broken": {"numwords": "lots"},
+			"typo": {"nuwords": 10}
+		}
+	}`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		preset  string
+		want    string // theme numwords showwpm time
+		wantErr string
+	}{
+		{"options", nil, "", "dark 25 true 0", ""},
+		{"flags win", []string{"-numwords", "5", "-theme", "light"}, "", "light 5 true 0", ""},
+		{"preset", nil, "sprint", "dark 10 true 15", ""},
+		{"flags win over preset", []string{"-numwords", "5"}, "sprint", "dark 5 true 15", ""},
+		{"missing preset", nil, "nope", "", "there is no nope preset"},
+		{"bad value", nil, "broken", "", "presets.broken: numwords"},
+		{"unknown option", nil, "typo", "", "there is no -nuwords option"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("gotype", flag.ContinueOnError)
+			flags.String("theme", "default", "")
+			flags.Int("numwords", 50, "")
+			flags.Bool("showwpm", false, "")
+			flags.Int("time", 0, "")
+			flags.String("preset", "", "")
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := config.apply(flags, tt.preset)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var values []string
+			for _, name := range []string{"theme", "numwords", "showwpm", "time"} {
+				values = append(values, flags.Lookup(name).Value.String())
+			}
+			if got := strings.Join(values, " "); got != tt.want {
+				t.Errorf("flags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
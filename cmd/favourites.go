@@ -0,0 +1,95 @@
+// Starred quotes, kept between runs
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const favouritesVersion = 1 // 版本, of favouritesFile
+
+// 收藏文件
+// 格式为
+//
+//	{
+//	  "version": 1,
+//	  "quotes": {
+//	    "english": [12, 345],
+//	    "german": [3]
+//	  }
+//	}
+type favouritesFile struct {
+	Version int              `json:"version"`
+	Quotes  map[string][]int `json:"quotes"` // starred quote ids by quote file
+}
+
+func favouritesPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "favourites.json"), nil
+}
+
+// loadFavourites reads the starred quotes. A missing file means nothing has
+// been starred yet.
+func loadFavourites() (map[string][]int, error) {
+	path, err := favouritesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	var favourites favouritesFile
+	err = readJSONFile(path, "favourites", favouritesVersion, &favourites)
+	if os.IsNotExist(err) {
+		return map[string][]int{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if favourites.Quotes == nil {
+		favourites.Quotes = map[string][]int{}
+	}
+	return favourites.Quotes, nil
+}
+
+// saveFavourites replaces the favourites file
+func saveFavourites(quotes map[string][]int) error {
+	path, err := favouritesPath()
+	if err != nil {
+		return err
+	}
+
+	return writeJSONFile(path, favouritesFile{favouritesVersion, quotes}, true)
+}
+
+// starQuote adds or, if star is false, removes quote id of quoteFile from
+// the favourites.
+func starQuote(favourites map[string][]int, quoteFile string, id int, star bool) error {
+	q, err := readQuoteFile(quoteFile)
+	if err != nil {
+		return fmt.Errorf("%s does not appear to be a valid quote file: %s", quoteFile, err)
+	}
+
+	if !slices.ContainsFunc(q.Quotes, func(quote quoteEntry) bool { return quote.ID == id }) {
+		return fmt.Errorf("%s has no quote with id %d, use -quotesearch to find one", quoteFile, id)
+	}
+
+	ids := slices.DeleteFunc(favourites[quoteFile], func(starred int) bool { return starred == id })
+	if star {
+		ids = append(ids, id)
+		slices.Sort(ids)
+	}
+
+	if len(ids) == 0 {
+		delete(favourites, quoteFile)
+	} else {
+		favourites[quoteFile] = ids
+	}
+
+	return saveFavourites(favourites)
+}
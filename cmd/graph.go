@@ -0,0 +1,160 @@
+// Draw the wpm graph shown on the report screen
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/gdamore/tcell"
+)
+
+var (
+	graphAxisStyle  = tcell.StyleDefault
+	graphWpmStyle   = tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	graphRawStyle   = tcell.StyleDefault.Dim(true)
+	graphErrorStyle = tcell.StyleDefault.Foreground(tcell.ColorRed)
+)
+
+// graphHeight is the number of rows used by the plot area, excluding the
+// x axis and its labels.
+const graphHeight = 10
+
+// graphDimensions returns the size of the graph drawn for the given samples
+// when at most maxWidth columns are available.
+func graphDimensions(samples []wpmSample, maxWidth int) (w, h int) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+
+	w = 60
+	if w > maxWidth {
+		w = maxWidth
+	}
+
+	return w, graphHeight + 2
+}
+
+// drawGraph draws a line chart of the net and raw wpm in samples, with a
+// marker for every second in which errors were made, in the w x h box at x, y.
+//
+//	96 ┤      ••••••••            ·     x
+//	   │  ••••   ·    •••••••••••••••
+//	   │••  · ·    ·  ·   x  ·
+//	 0 └───────────────────────────────
+//	   1                            30s
+func drawGraph(scr tcell.Screen, x, y, w, h int, samples []wpmSample) {
+	if len(samples) == 0 || h < 4 {
+		return
+	}
+
+	maxWpm := 0
+	maxErrs := 0
+	for _, s := range samples {
+		if s.Wpm > maxWpm {
+			maxWpm = s.Wpm
+		}
+		if s.Raw > maxWpm {
+			maxWpm = s.Raw
+		}
+		if s.Errors > maxErrs {
+			maxErrs = s.Errors
+		}
+	}
+
+	if maxWpm == 0 {
+		maxWpm = 1
+	}
+
+	// Leave room for the axis labels on either side
+	labelw := len(strconv.Itoa(maxWpm)) + 1
+	errlabelw := len(strconv.Itoa(maxErrs)) + 1
+	pw := w - labelw - 1 - errlabelw
+	ph := h - 2
+	if pw < 2 || ph < 2 {
+		return
+	}
+
+	px := x + labelw + 1 // left edge of the plot area
+	py := y              // top edge of the plot area
+
+	// row maps v in [0, max] to a row of the plot area, 0 being the bottom row
+	row := func(v, max int) int {
+		return py + ph - 1 - v*(ph-1)/max
+	}
+
+	// value interpolates the samples at column c of the plot area
+	value := func(c int, get func(wpmSample) int) int {
+		if len(samples) == 1 {
+			return get(samples[0])
+		}
+
+		pos := float64(c) * float64(len(samples)-1) / float64(pw-1)
+		i := int(pos)
+		if i >= len(samples)-1 {
+			return get(samples[len(samples)-1])
+		}
+
+		frac := pos - float64(i)
+		return int(float64(get(samples[i]))*(1-frac) + float64(get(samples[i+1]))*frac + 0.5)
+	}
+
+	// Axes
+	drawString(scr, x, py, padLeft(strconv.Itoa(maxWpm), labelw-1), -1, graphAxisStyle)
+	drawString(scr, x, py+ph, padLeft("0", labelw-1), -1, graphAxisStyle)
+	for r := py; r < py+ph; r++ {
+		scr.SetContent(px-1, r, '│', nil, graphAxisStyle)
+	}
+	scr.SetContent(px-1, py, '┤', nil, graphAxisStyle)
+	scr.SetContent(px-1, py+ph, '└', nil, graphAxisStyle)
+	for c := 0; c < pw; c++ {
+		scr.SetContent(px+c, py+ph, '─', nil, graphAxisStyle)
+	}
+
+	end := strconv.Itoa(len(samples)) + "s"
+	drawString(scr, px, py+ph+1, "1", -1, graphAxisStyle)
+	drawString(scr, px+pw-len(end), py+ph+1, end, -1, graphAxisStyle)
+
+	if maxErrs > 0 {
+		drawString(scr, px+pw+1, py, strconv.Itoa(maxErrs), -1, graphErrorStyle)
+		drawString(scr, px+pw+1, py+ph, "0", -1, graphErrorStyle)
+	}
+
+	// Lines, raw first so the net wpm is drawn over it
+	for c := 0; c < pw; c++ {
+		raw := value(c, func(s wpmSample) int { return s.Raw })
+		scr.SetContent(px+c, row(raw, maxWpm), '·', nil, graphRawStyle)
+	}
+
+	for c := 0; c < pw; c++ {
+		wpm := value(c, func(s wpmSample) int { return s.Wpm })
+		scr.SetContent(px+c, row(wpm, maxWpm), '•', nil, graphWpmStyle)
+	}
+
+	// Error markers, on their own scale
+	for i, s := range samples {
+		if s.Errors == 0 {
+			continue
+		}
+
+		c := 0
+		if len(samples) > 1 {
+			c = i * (pw - 1) / (len(samples) - 1)
+		}
+
+		scr.SetContent(px+c, row(s.Errors, maxErrs), 'x', nil, graphErrorStyle)
+	}
+
+	legend := "• wpm  · raw"
+	if maxErrs > 0 {
+		legend += "  x errors"
+	}
+	drawString(scr, px+(pw-len([]rune(legend)))/2, py+ph+1, legend, -1, graphAxisStyle)
+}
+
+func padLeft(s string, n int) string {
+	for len(s) < n {
+		s = " " + s
+	}
+
+	return s
+}
@@ -0,0 +1,127 @@
+// Persist test results between runs
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const historyVersion = 1 // 版本, of historyFile
+
+// 历史文件
+// 格式为
+//
+//	{
+//	  "version": 1,
+//	  "results": [
+//	    {
+//	      "mode": "words",
+//	      "source": "english_1k",
+//	      "wpm": 72,
+//	      ...
+//	    },
+//	    ...
+//	  ]
+//	}
+type historyFile struct {
+	Version int      `json:"version"`
+	Results []result `json:"results"`
+}
+
+// dataDir returns the directory gotype keeps user data in, following the XDG
+// base directory spec ($XDG_DATA_HOME/gotype, falling back to ~/.local/share/gotype).
+func dataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "gotype"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".local", "share", "gotype"), nil
+}
+
+// configDir returns the directory gotype reads user configuration from,
+// $XDG_CONFIG_HOME/gotype, falling back to ~/.config/gotype.
+func configDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gotype"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config", "gotype"), nil
+}
+
+func historyPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "history.json"), nil
+}
+
+// loadHistory reads every stored result. A missing history file is not an
+// error, it just means no test has been completed yet.
+func loadHistory() ([]result, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+
+	var history historyFile
+	err = readJSONFile(path, "history", historyVersion, &history)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return history.Results, nil
+}
+
+// appendHistory adds res to the history file. The file is locked while it is
+// read again and written back, so results saved by other gotype sessions in
+// the meantime are kept.
+func appendHistory(res result) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	results, err := loadHistory()
+	if err != nil {
+		return err
+	}
+
+	return writeJSONFile(path, historyFile{historyVersion, append(results, res)}, true)
+}
+
+// printHistory prints stored results oldest first, one per line.
+func printHistory(results []result) {
+	if len(results) == 0 {
+		fmt.Println("No results yet")
+		return
+	}
+
+	fmt.Printf("%-16s  %-6s  %-20s  %5s  %5s  %5s  %8s  %11s  %s\n", "Date", "Mode", "Source", "WPM", "Raw", "CPM", "Accuracy", "Consistency", "Mistakes")
+	for _, r := range results {
+		fmt.Printf("%-16s  %-6s  %-20s  %5d  %5d  %5d  %7.2f%%  %10.2f%%  %d\n",
+			time.Unix(r.Timestamp, 0).Format("2006-01-02 15:04"), r.Mode, r.Source, r.Wpm, r.RawWpm, r.Cpm, r.Accuracy, r.Consistency, len(r.Mistakes))
+	}
+}
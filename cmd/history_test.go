@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestAppendHistory(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dir)
+
+	// Every saved result is kept, none replaces the ones before it
+	for _, wpm := range []int{60, 70} {
+		if err := appendHistory(result{Mode: "words", Wpm: wpm}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Wpm != 60 || results[1].Wpm != 70 {
+		t.Errorf("history = %+v, want both results", results)
+	}
+
+	files, _ := os.ReadDir(filepath.Join(dir, "gotype"))
+	if len(files) != 1 {
+		t.Errorf("data directory has %d files, want only history.json", len(files))
+	}
+}
+
+// Sessions saving at the same time don't lose each other's results
+func TestAppendHistoryConcurrent(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(wpm int) {
+			defer wg.Done()
+			if err := appendHistory(result{Mode: "words", Wpm: wpm}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	results, err := loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[int]bool{}
+	for _, r := range results {
+		seen[r.Wpm] = true
+	}
+	if len(results) != n || len(seen) != n {
+		t.Errorf("history has %d results, %d different, want %d", len(results), len(seen), n)
+	}
+}
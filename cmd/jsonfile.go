@@ -0,0 +1,113 @@
+// Versioned json files, kept in the config and data directories
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	lockRetry = 10 * time.Millisecond // 重试间隔, how often a held lock is tried again
+	lockWait  = 5 * time.Second       // how long a lock is waited on before giving up
+	lockStale = 30 * time.Second      // 过期, a lock this old was left behind by a gotype which died
+)
+
+// Every json file gotype keeps has a "version", bumped whenever the layout of
+// the file changes in a way older versions of gotype can't read. Files with a
+// newer version than this gotype knows are refused rather than misread.
+
+// readJSONFile reads the json file at path into v. kind names the file in
+// errors and version is the newest version of it this gotype reads. A missing
+// file is returned as an error satisfying os.IsNotExist.
+func readJSONFile(path string, kind string, version int, v any) error {
+	res, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(res, &header); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	if header.Version > version {
+		return fmt.Errorf("%s was written by a newer version of gotype (%s version %d)", path, kind, header.Version)
+	}
+
+	if err := json.Unmarshal(res, v); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return nil
+}
+
+// writeJSONFile writes v to path. It is written to a temporary file of its
+// own in the same directory first, so an interrupted write or another gotype
+// writing at the same time can't leave a half written file behind. With
+// replace false an existing file is kept and an error satisfying os.IsExist
+// returned.
+func writeJSONFile(path string, v any, replace bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails once renamed
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if !replace {
+		return os.Link(tmp.Name(), path)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// lockFile takes an exclusive lock on path, held by creating path.lock, for
+// gotype sessions which read a file and write it back. It waits for a lock
+// held by another session, and breaks one left behind by a session which
+// didn't get to unlock it. The returned function releases the lock.
+func lockFile(path string) (unlock func(), err error) {
+	lock := path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lock), 0755); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockWait)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked by another gotype, remove %s if none is running", path, lock)
+		}
+		time.Sleep(lockRetry)
+	}
+}
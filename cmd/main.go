@@ -3,8 +3,11 @@ package main
 import (
 	// fmt包提供了I/O函数
 
+	"context"
+	"errors"
 	"flag" // flag包实现了命令行参数的解析
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,24 +17,100 @@ import (
 )
 
 type result struct {
-	Wpm       int       `json:"wpm"`
-	Cpm       int       `json:"cpm"`
-	Accuracy  float64   `json:"accuracy"`
-	Timestamp int64     `json:"timestamp"`
-	Mistakes  []mistake `json:"mistakes"`
+	Mode     string  `json:"mode"`     // words, quotes, wllm, qllm, zen, custom or code
+	Source   string  `json:"source"`   // word/quote file, model or custom text/code file the test came from
+	NumWords int     `json:"numwords"` // words per segment, 0 for quotes
+	Timeout  int     `json:"timeout"`  // time limit in seconds, -1 for none
+	Duration float64 `json:"duration"` // time taken in seconds
+	Seed     int64   `json:"seed"`     // 随机种子, generates the same text again with -seed and the same options
+
+	Punctuation bool `json:"punctuation,omitempty"` // words had punctuation added
+	Numbers     bool `json:"numbers,omitempty"`     // numbers were mixed in with the words
+
+	QuoteLength string `json:"quotelength,omitempty"` // length group of the quote, short, medium, long or thicc
+
+	Wpm         int       `json:"wpm"`
+	RawWpm      int       `json:"raw"`
+	Cpm         int       `json:"cpm"`
+	Accuracy    float64   `json:"accuracy"`    // percentage of correct keystrokes
+	Consistency float64   `json:"consistency"` // steadiness of the raw wpm, 0-100
+	Keystrokes  int       `json:"keystrokes"`
+	Corrected   int       `json:"corrected"`   // errors which were deleted again
+	Uncorrected int       `json:"uncorrected"` // errors left in the text
+	Timestamp   int64     `json:"timestamp"`
+	Mistakes    []mistake `json:"mistakes"`
+
+	Wpms      []wpmSample `json:"wpms"`                // one sample per second of the test
+	Recording string      `json:"recording,omitempty"` // id of the keystroke recording, if -record was used
+}
 
-	Wpms []int `json:"wpms"`
+// newResult calculates the metrics of a completed test
+func newResult(stats testStats, dur time.Duration, mistakes []mistake) result {
+	return result{
+		Duration:    dur.Seconds(),
+		Wpm:         stats.Wpm(dur),
+		RawWpm:      stats.RawWpm(dur),
+		Cpm:         int(float64(stats.WordChars) / (float64(dur) / 60e9)),
+		Accuracy:    stats.Accuracy(),
+		Consistency: stats.Consistency(),
+		Keystrokes:  stats.Keystrokes,
+		Corrected:   stats.Corrected,
+		Uncorrected: stats.Uncorrected,
+		Timestamp:   time.Now().Unix(),
+		Mistakes:    mistakes,
+		Wpms:        stats.Samples,
+	}
+}
+
+// saveResult adds res to the history
+func saveResult(res result) {
+	if err := appendHistory(res); err != nil {
+		scr.Fini()
+		exit("Error saving history: %s\n", err)
+	}
 }
 
 var usage = `usage: gotype [options] [file]
+       gotype [options] replay [-speed n] <id|file>
+
+Options default to those in $XDG_CONFIG_HOME/gotype/config.json, and the -preset from it.
+
+With a file, the text of the file is typed, - reads the text from stdin.
+
+Commands
+	replay		Play back a test recorded with -record, at -speed times real speed.
+			Space pauses, left/right seek, up/down change the speed.
 
 Modes
-  	-words		string 		Specify the words file to use
-  	-quotes 	string 		Specify the quotes file to use
+  	-source		string		Take the text from this source, a name and its argument such as quotes:german (see -list sources)
+  	-words		string 		Specify the words file to use, or a size of the -language list such as 10k
+  	-quotes 	string 		Specify the quotes file or language to use
+  	-quotelength	string		Only use quotes of this length, short, medium, long, thicc or all (default all)
+  	-quoteid	int			Type the quote with this id, from -quotes or the -language quotes
+  	-quotesearch	string		List the quotes whose text or source contains this and exit
+  	-starred	bool		Only use starred quotes
+  	-star		int			Star the quote with this id and exit
+  	-unstar		int			Unstar the quote with this id and exit
+  	-wllm		string		Generate the words with this Ollama model
+  	-qllm		string		Generate a quote with this Ollama model
+  	-ollamahost	string		Address of the Ollama server (default $OLLAMA_HOST or localhost:11434)
+  	-llmtimeout	int			Seconds to wait for the model to generate text, 0 for no limit (default 120)
+  	-zen		bool		Type freely with no target text, ctrl+d finishes the test
+  	-code		string		Type the source code in this file, - for stdin, keeping its lines and indentation
+  	-typeindent	bool		Type the indentation of code rather than skipping it
+  	-tabwidth	int			Spaces a tab in code is shown as, and the tab key types (default 4)
 
 Play
+	- language	string		Language of the default word list and of generated text (default english)
+	- seed		int			Seed the text of the tests, the same seed, mode and options give the same text
 	- numwords	int			Number of words to use in the test
+	- frequency	float		Weight words by how common they are, from 0 (uniform) to 1 (as in natural language), for word lists ordered by frequency
+	- punctuation	bool		Capitalise sentences and add punctuation to word tests
+	- numbers	bool			Mix numbers in with the words of word tests
 	- numsegments	int		Number of segments to use in the test (number of tests)
+	- timeout	int			Timeout in seconds
+	- time		int			Time mode, type words streamed from the source for this many seconds (15, 30, 60, 120)
+	- oneshot	bool		Exit after one test
 
 Display
 	- showwpm		bool		Show words per minute
@@ -39,13 +118,16 @@ Display
 	- theme 		string		The theme to use
  
 Misc
+	- preset		string		Use the options of this preset from the config file
+	- record		bool		Record keystrokes so tests can be replayed
+	- list			string		List available themes, words, quotes, sources or presets
+	- history		bool		Show the results of previous tests
 	- version		bool		Show the version
 `
 
 // Global variables
 var scr tcell.Screen // scr是一个tcell.Screen
 var err error
-var results []result
 
 func main() {
 	// Flags
@@ -55,6 +137,8 @@ func main() {
 	var versionFlag bool
 	var helpFlag bool
 	var listFlag string
+	var presetName string
+	var historyFlag bool
 
 	// GoType flags
 	var noSkip bool
@@ -62,18 +146,37 @@ func main() {
 	var normalCursor bool
 	var showWpm bool
 	var timeout int
+	var timeMode int
 	var oneShotMode bool
 	var numWords int
 	var numSegments int
-
-	var wordFile string  // -words flag
-	var quoteFile string // -quotes flag
-	var wordLlm string   //
-	var quoteLlm string  //
+	var recordFlag bool
+
+	var sourceSpec string // -source flag
+	var wordFile string   // -words flag
+	var quoteFile string  // -quotes flag
+	var wordLlm string    //
+	var quoteLlm string   //
+	var ollamaHost string
+	var llmTimeout int
+	var zenFlag bool    // -zen flag
+	var codeFile string // -code flag
+	var typeIndent bool
+	var tabWidth int
+	var language string // -language flag
+	var quoteLength string
+	var quoteID int
+	var quoteSearch string
+	var starredFlag bool
+	var starID int
+	var unstarID int
+	var mods textModifiers
+	var frequency float64
 
 	// var typingTestWordsFile string        // 单词文件
 	// var typingTestQuotesFile string       // 引用文件
-	var typingTestGetter func() []segment // 函数返回单词的数组
+	var typingTestGetter func(seed int64) []segment // 函数返回单词的数组
+	var seed int64
 
 	// Set flags
 	flag.StringVar(&themeName, "theme", "default", "The theme to use")
@@ -82,45 +185,75 @@ func main() {
 	flag.BoolVar(&versionFlag, "version", false, "Show the version")
 	flag.BoolVar(&helpFlag, "help", false, "Show the help")
 	flag.StringVar(&listFlag, "list", "", "List available themes and word files")
+	flag.BoolVar(&historyFlag, "history", false, "Show the results of previous tests")
+	flag.StringVar(&presetName, "preset", "", "Use the options of this preset from the config file")
 
+	flag.StringVar(&sourceSpec, "source", "", "Take the text from this source")
 	flag.StringVar(&wordFile, "words", "", "Specify the words file to use")
 	flag.StringVar(&quoteFile, "quotes", "", "Specify the quotes file to use")
+	flag.StringVar(&quoteLength, "quotelength", "all", "Only use quotes of this length")
+	flag.IntVar(&quoteID, "quoteid", 0, "Type the quote with this id")
+	flag.StringVar(&quoteSearch, "quotesearch", "", "List the quotes matching this text")
+	flag.BoolVar(&starredFlag, "starred", false, "Only use starred quotes")
+	flag.IntVar(&starID, "star", 0, "Star the quote with this id")
+	flag.IntVar(&unstarID, "unstar", 0, "Unstar the quote with this id")
 	flag.StringVar(&wordLlm, "wllm", "", "Specify the language model to use")
 	flag.StringVar(&quoteLlm, "qllm", "", "Specify the language model to use")
+	flag.StringVar(&ollamaHost, "ollamahost", "", "Address of the Ollama server")
+	flag.IntVar(&llmTimeout, "llmtimeout", 120, "Seconds to wait for the model to generate text")
+	flag.BoolVar(&zenFlag, "zen", false, "Type freely with no target text")
+	flag.StringVar(&codeFile, "code", "", "Type the source code in this file")
+	flag.BoolVar(&typeIndent, "typeindent", false, "Type the indentation of code")
+	flag.IntVar(&tabWidth, "tabwidth", 4, "Spaces a tab in code is shown as")
+	flag.StringVar(&language, "language", "english", "Language of the default word list and of generated text")
 
 	flag.BoolVar(&noSkip, "noskip", false, "Don't skip words")
 	flag.BoolVar(&noBackspace, "nobackspace", false, "Don't allow backspace")
 	flag.BoolVar(&normalCursor, "blockcursor", false, "Use a normal cursor")
 	flag.BoolVar(&showWpm, "showwpm", false, "Show words per minute")
 	flag.IntVar(&timeout, "timeout", -1, "Timeout in seconds")
+	flag.IntVar(&timeMode, "time", 0, "Time mode, type streamed words for this many seconds")
 	flag.BoolVar(&oneShotMode, "oneshot", false, "Exit after one test")
+	flag.Int64Var(&seed, "seed", 0, "Seed the text of the tests")
 	flag.IntVar(&numWords, "numwords", 50, "Number of words to use in the test")
+	flag.Float64Var(&frequency, "frequency", 0, "Weight words by how common they are, from 0 to 1")
+	flag.BoolVar(&mods.Punctuation, "punctuation", false, "Capitalise sentences and add punctuation to word tests")
+	flag.BoolVar(&mods.Numbers, "numbers", false, "Mix numbers in with the words of word tests")
 	flag.IntVar(&numSegments, "numsegments", 1, "Number of segments to use in the test")
+	flag.BoolVar(&recordFlag, "record", false, "Record keystrokes so tests can be replayed")
 
 	flag.Usage = func() { os.Stdout.Write([]byte(usage)) } // flag.Usage是一个函数，用于打印使用信息
 	flag.Parse()                                           // 解析命令行参数
 
-	// List flag
-	prefix := ""
-	if listFlag != "" {
-		if listFlag == "words" || listFlag == "quotes" {
-			prefix = "./data//" + listFlag + "/"
-		} else {
-			prefix = "./" + listFlag + "/"
-		}
+	// Options from the config file, for the flags not given on the command line
+	var config configFile
+	if config, err = loadConfig(); err != nil {
+		exit("Error reading config: %s\n", err)
+	}
+	if err := config.apply(flag.CommandLine, presetName); err != nil {
+		exit("Error in config: %s\n", err)
+	}
 
-		// List files located at the prefix
-		fmt.Println("Files in " + prefix)
-		files, err := os.ReadDir(prefix)
-		if err != nil {
-			exit("Error listing files: %s", err)
+	// List flag
+	if listFlag == "sources" {
+		printSources()
+		os.Exit(0)
+	} else if listFlag == "presets" {
+		printPresets(config)
+		os.Exit(0)
+	} else if listFlag == "words" || listFlag == "quotes" {
+		printPacks(listFlag)
+		os.Exit(0)
+	} else if listFlag != "" {
+		// List the files of the directory in every layer
+		files := listDataDir(listFlag)
+		if len(files) == 0 {
+			exit("Nothing to list in %s\n", listFlag)
 		}
 
+		fmt.Println("Files in " + listFlag)
 		for _, file := range files {
-			if file.IsDir() {
-				continue
-			}
-			fmt.Println(strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())))
+			fmt.Printf("  %-16s %s\n", strings.TrimSuffix(file.Name, filepath.Ext(file.Name)), file.Layer)
 		}
 
 		os.Exit(0)
@@ -132,18 +265,190 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Set the typing test getter
+	// Load previous results
+	var results []result
+	if results, err = loadHistory(); err != nil {
+		exit("Error reading history: %s\n", err)
+	}
+
+	// History flag
+	if historyFlag {
+		printHistory(results)
+		os.Exit(0)
+	}
+
+	// Quote selection, from the pack of -source quotes:<pack> or -quotes, by
+	// default from the quotes of the -language. The quotes source resolves
+	// its own pack, which is the one starred quotes are kept for.
+	if name, _, _ := strings.Cut(sourceSpec, ":"); name == "quotes" {
+		quotes, err := newSource(sourceSpec, sourceOptions{Language: language})
+		if err != nil {
+			exit("%s\n", err)
+		}
+		quoteFile = quotes.Meta().Source
+	}
+	if quoteFile == "" && (quoteID != 0 || quoteSearch != "" || starredFlag || starID != 0 || unstarID != 0) {
+		quoteFile = language
+	}
+	if quoteFile != "" {
+		quoteFile = resolveQuoteFile(quoteFile, language)
+	}
+
+	var favourites map[string][]int
+	if favourites, err = loadFavourites(); err != nil {
+		exit("Error reading favourites: %s\n", err)
+	}
+
+	if starID != 0 || unstarID != 0 {
+		id, star := starID, true
+		if unstarID != 0 {
+			id, star = unstarID, false
+		}
+		if err := starQuote(favourites, quoteFile, id, star); err != nil {
+			exit("Error starring quote: %s\n", err)
+		}
+
+		if star {
+			fmt.Printf("Starred quote %d of %s\n", id, quoteFile)
+		} else {
+			fmt.Printf("Unstarred quote %d of %s\n", id, quoteFile)
+		}
+		os.Exit(0)
+	}
+
+	if quoteSearch != "" {
+		printQuoteSearch(quoteFile, quoteSearch, favourites[quoteFile])
+		os.Exit(0)
+	}
+
+	// Replay command
+	var replayRec *recording
+	var replaySpeed float64
+	if flag.Arg(0) == "replay" {
+		replayFlags := flag.NewFlagSet("replay", flag.ExitOnError)
+		replayFlags.Float64Var(&replaySpeed, "speed", 1, "Playback speed")
+		replayFlags.Parse(flag.Args()[1:])
+
+		if replayFlags.NArg() != 1 {
+			exit("usage: gotype [options] replay [-speed n] <id|file>\n")
+		}
+		if replaySpeed <= 0 {
+			exit("-speed must be greater than 0\n")
+		}
+		if replayRec, err = loadRecording(replayFlags.Arg(0)); err != nil {
+			exit("Error loading recording: %s\n", err)
+		}
+	}
+
+	if frequency < 0 || frequency > 1 {
+		exit("-frequency must be between 0 and 1\n")
+	}
+
+	// Without -seed every run gets its own seed, test n of a run uses seed+n
+	seedSet := false
+	flag.Visit(func(f *flag.Flag) { seedSet = seedSet || f.Name == "seed" })
+	if !seedSet {
+		seed = rand.Int63n(1e9)
+	}
+
+	// Source of the text, named by -source or by the flag of the source
+	var mode string // recorded with each result
+	var src Source
 	switch {
-	case wordFile != "":
-		typingTestGetter = generateWordsTestFromFile(wordFile, numWords, numSegments)
-	case quoteFile != "":
-		typingTestGetter = generateQuoteTestFromFile(quoteFile)
-	case wordLlm != "":
-		typingTestGetter = generateTestFromLLM("words", wordLlm, numWords)
-	case quoteLlm != "":
-		typingTestGetter = generateTestFromLLM("quote", quoteLlm, numWords)
+	case replayRec != nil:
+		// The text comes from the recording
+	case zenFlag:
+		// Zen has no text, so nothing to take it from
+		if flag.NArg() > 0 || codeFile != "" || sourceSpec != "" {
+			exit("-zen can't be used with a file, -code or -source\n")
+		}
+		if recordFlag {
+			exit("-record can't be used with -zen, there is no text to replay\n")
+		}
+		mode = "zen"
 	default:
-		typingTestGetter = generateWordsTestFromFile("english_1k", numWords, numSegments)
+		spec := sourceSpec
+		switch {
+		case flag.NArg() > 1:
+			exit("usage: gotype [options] [file]\n")
+		case flag.NArg() == 1:
+			spec = "custom:" + flag.Arg(0)
+		case codeFile != "":
+			spec = "code:" + codeFile
+		case spec != "":
+		case wordFile != "":
+			spec = "words:" + wordFile
+		case quoteFile != "":
+			spec = "quotes:" + quoteFile
+		case wordLlm != "":
+			spec = "wllm:" + wordLlm
+		case quoteLlm != "":
+			spec = "qllm:" + quoteLlm
+		default:
+			spec = "words"
+		}
+
+		var ids []int // nil for every quote
+		if quoteID != 0 {
+			ids = []int{quoteID}
+		} else if starredFlag {
+			if ids = favourites[quoteFile]; len(ids) == 0 {
+				exit("No quotes of %s are starred, star some with -star <id>\n", quoteFile)
+			}
+		}
+
+		src, err = newSource(spec, sourceOptions{
+			Language:    language,
+			NumWords:    numWords,
+			NumSegments: numSegments,
+			Mods:        mods,
+			Frequency:   frequency,
+			QuoteLength: quoteLength,
+			QuoteIDs:    ids,
+			OllamaHost:  ollamaHost,
+			LLMTimeout:  time.Duration(llmTimeout) * time.Second,
+			TabWidth:    tabWidth,
+		})
+		if err != nil {
+			exit("%s\n", err)
+		}
+		mode = src.Name()
+
+		if src.Meta().Code && timeMode > 0 {
+			exit("-time can't be used with code\n")
+		}
+
+		// Errors getting the text are shown with the option to try again
+		typingTestGetter = func(seed int64) []segment {
+			for {
+				test, err := src.Next(context.Background(), scr, seed)
+				if err == nil {
+					return test
+				}
+				if errors.Is(err, errCancelled) || !showSourceError(scr, src.Meta().Source, err) {
+					exit_program(1)
+				}
+			}
+		}
+	}
+
+	// Time mode ends only when the clock runs out
+	if timeMode < 0 {
+		exit("-time must be a number of seconds, such as 15, 30, 60 or 120\n")
+	} else if timeMode > 0 {
+		timeout = timeMode
+	}
+
+	resultNumWords := 0
+	if src != nil && timeMode == 0 {
+		resultNumWords = src.Options().NumWords
+	}
+	resultTimeout := timeout
+
+	// Theme, checked before the screen is set up so errors can be printed
+	var th theme
+	if th, err = readTheme(themeName); err != nil {
+		exit("Error in theme: %s\n", err)
 	}
 
 	// Set up screen
@@ -163,39 +468,109 @@ func main() {
 		}
 	}()
 
-	//	TODO Set up theme
-
 	// Set up gotype object
-	var gotype *gotype = createGoType(scr, boldFlag, themeName) // TODO
+	var gotype *gotype = createGoType(scr, boldFlag, th)
 
 	gotype.SkipWord = !noSkip
 	gotype.DisableBackspace = noBackspace
 	gotype.BlockCursor = normalCursor
 	gotype.ShowWpm = showWpm
+	if src != nil && src.Meta().Code { // spaces in code are typed, there are no words to skip
+		gotype.Code = true
+		gotype.SkipWord = false
+		gotype.TypeIndent = typeIndent
+		gotype.TabWidth = tabWidth
+	}
+	if recordFlag {
+		gotype.Recorder = &recorder{}
+	}
+	// Sources which can get text ready ahead are told the seeds asked for next
+	prefetch := func(seeds ...int64) {
+		if p, ok := src.(prefetcher); ok {
+			p.Prefetch(seeds...)
+		}
+	}
+
+	var streamSeed int64 // seed of the last streamed text, consecutive so the next one can be prefetched
+	var upcoming []int64 // seed of the test after the current one, if it hasn't been got yet
+	if timeMode > 0 && src != nil {
+		gotype.Stream = func() string {
+			streamSeed++
+			text := wrapText(scr, joinSegments(typingTestGetter(streamSeed)), 80)
+			prefetch(append([]int64{streamSeed + 1}, upcoming...)...)
+			return text
+		}
+	}
+
+	if replayRec != nil {
+		replay(gotype, replayRec, replaySpeed)
+		exit_program(0)
+	}
 
 	if timeout != -1 { // 如果timeout不为0, 则
 		timeout *= 1e9
 	}
 
+	// Zen mode has no text to type, so no tests to move between
+	if zenFlag {
+		for {
+			dur, rc, stats := gotype.Zen(time.Duration(timeout))
+
+			switch rc {
+			case GoTypeComplete:
+				res := newResult(stats, dur, nil)
+				res.Mode = mode
+				res.Timeout = resultTimeout
+				saveResult(res)
+
+				showReport(scr, res, "")
+				if oneShotMode {
+					exit_program(0)
+				}
+			case GoTypeSigInt:
+				exit_program(1)
+			}
+		}
+	}
+
 	var tests [][]segment  // 测试数组
+	var seeds []int64      // 每个测试的种子, seed of each test
 	var currentTestIdx int // 当前测试
 
 	// Logic loop
 	for {
 		if currentTestIdx >= len(tests) { // 如果当前测试索引大于等于测试数组的长度
-			tests = append(tests, typingTestGetter()) // 将新的测试添加到测试数组
+			testSeed := seed + int64(len(tests))
+			test := typingTestGetter(testSeed)
+			if timeMode > 0 && len(test) > 0 { // 时间模式, more text is streamed in as the test goes
+				test = []segment{{Text: joinSegments(test), Attribution: test[0].Attribution}}
+			}
+			tests = append(tests, test) // 将新的测试添加到测试数组
+			seeds = append(seeds, testSeed)
 		}
 
 		if tests[currentTestIdx] == nil { // 如果当前测试为空
 			exit("No tests available")
 		}
 
-		// wrap the text
+		// wrap the text, code keeps its own lines
 		for idx, _ := range tests[currentTestIdx] {
-			tests[currentTestIdx][idx].Text = wrapText(tests[currentTestIdx][idx].Text, 80)
+			if !gotype.Code {
+				tests[currentTestIdx][idx].Text = wrapText(scr, tests[currentTestIdx][idx].Text, 80)
+			}
 		}
 
-		numerrors, numcorrect, dur, rc, mistakes, wpms := gotype.StartTest(tests[currentTestIdx], time.Duration(timeout)) // 开始测试
+		streamSeed = rand.New(rand.NewSource(seeds[currentTestIdx])).Int63()
+		upcoming = nil
+		if currentTestIdx == len(tests)-1 {
+			upcoming = []int64{seed + int64(len(tests))}
+		}
+		if timeMode > 0 {
+			prefetch(append([]int64{streamSeed + 1}, upcoming...)...)
+		} else {
+			prefetch(upcoming...)
+		}
+		_, _, dur, rc, mistakes, stats := gotype.StartTest(tests[currentTestIdx], time.Duration(timeout)) // 开始测试
 
 		switch rc {
 		case GoTypeNext:
@@ -203,17 +578,41 @@ func main() {
 		case GoTypePrevious:
 			currentTestIdx--
 		case GoTypeComplete:
-			cpm := int(float64(numcorrect) / (float64(dur) / 60e9))
-			wpm := cpm / 5
-			accuracy := float64(numcorrect) / float64(numerrors+numcorrect) * 100
+			res := newResult(stats, dur, mistakes)
+			res.Mode = mode
+			res.Source = src.Meta().Source
+			res.NumWords = resultNumWords
+			res.Seed = seeds[currentTestIdx]
+			res.Punctuation, res.Numbers = src.Options().Mods.Punctuation, src.Options().Mods.Numbers
+			res.Timeout = resultTimeout
+			if len(tests[currentTestIdx]) == 1 {
+				res.QuoteLength = tests[currentTestIdx][0].Group
+			}
+
+			if gotype.Recorder != nil {
+				res.Recording, err = saveRecording(recording{
+					Segments:   gotype.Recorder.segments,
+					Timeout:    resultTimeout,
+					SkipWord:   gotype.SkipWord,
+					Code:       gotype.Code,
+					TypeIndent: gotype.TypeIndent,
+					TabWidth:   gotype.TabWidth,
+					Events:     gotype.Recorder.events,
+				})
+				if err != nil {
+					scr.Fini()
+					exit("Error saving recording: %s\n", err)
+				}
+			}
+
+			saveResult(res)
 
-			results = append(results, result{wpm, cpm, accuracy, time.Now().Unix(), mistakes, wpms})
 			// if !noReport {
 			attribution := ""
 			if len(tests[currentTestIdx]) == 1 {
 				attribution = tests[currentTestIdx][0].Attribution
 			}
-			showReport(scr, cpm, wpm, accuracy, attribution, mistakes)
+			showReport(scr, res, attribution)
 
 			// }
 			if oneShotMode {
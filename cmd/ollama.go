@@ -0,0 +1,307 @@
+// Generate text with an Ollama server
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/xyproto/ollamaclient"
+)
+
+const llmTemperature = 0.8 // Ollama's own default, the seed still makes it repeatable
+
+// llmHTTPClient has no timeout of its own, a generation is limited by the
+// context of generate alone so -llmtimeout 0 waits for as long as it takes
+var llmHTTPClient = &http.Client{}
+
+func init() {
+	registerSource("wllm", "words generated by an Ollama model, wllm:<model>", newLLMSource("words"))
+	registerSource("qllm", "a quote generated by an Ollama model, qllm:<model>", newLLMSource("quote"))
+}
+
+// llmClient asks an Ollama server for text, streaming the output back as it
+// is generated
+type llmClient struct {
+	*ollamaclient.Config               // API is the server address, Model the model to use
+	Timeout              time.Duration // 请求超时, for a whole generation, 0 for none
+}
+
+// newLLMClient returns a client for model on host. An empty host is taken
+// from $OLLAMA_HOST, falling back to localhost:11434.
+func newLLMClient(model string, host string, timeout time.Duration) *llmClient {
+	config := ollamaclient.NewWithModel(model)
+	if host != "" {
+		config = ollamaclient.NewWithModelAndAddr(model, host)
+	}
+
+	if !strings.Contains(config.API, "://") {
+		config.API = "http://" + config.API
+	}
+	config.API = strings.TrimSuffix(config.API, "/")
+
+	return &llmClient{Config: config, Timeout: timeout}
+}
+
+// generate sends prompt to the server and returns the whole response. Every
+// piece of the response is passed to onToken as it arrives.
+func (c *llmClient) generate(ctx context.Context, prompt string, seed int64, onToken func(string)) (string, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	body, err := json.Marshal(ollamaclient.GenerateRequest{
+		Model:  c.Model,
+		Prompt: prompt,
+		Options: ollamaclient.RequestOptions{
+			Seed:        int(seed),
+			Temperature: llmTemperature,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.API+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := llmHTTPClient.Do(req)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("%s took longer than %s to respond", c.Model, c.Timeout)
+	} else if err != nil {
+		return "", fmt.Errorf("can't reach the ollama server at %s, is it running? (%s)", c.API, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		b, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(b, &apiErr) != nil || apiErr.Error == "" {
+			apiErr.Error = strings.TrimSpace(string(b))
+		}
+		return "", fmt.Errorf("%s: %s", resp.Status, apiErr.Error)
+	}
+
+	// The response streams in as one json object per line
+	var sb strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var genResp ollamaclient.GenerateResponse
+		if err := decoder.Decode(&genResp); err == io.EOF {
+			return "", fmt.Errorf("the response from %s ended early", c.API)
+		} else if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("%s took longer than %s to respond", c.Model, c.Timeout)
+		} else if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(genResp.Response)
+		if onToken != nil && genResp.Response != "" {
+			onToken(genResp.Response)
+		}
+
+		if genResp.Done {
+			return strings.TrimSpace(sb.String()), nil
+		}
+	}
+}
+
+// llmJob is a generation running in the background
+type llmJob struct {
+	seed   int64
+	cancel context.CancelFunc
+	done   chan struct{} // closed once text and err are set
+
+	mu       sync.Mutex
+	streamed strings.Builder // 已生成的文本, output so far
+	onUpdate func()          // called as output arrives, while the job is waited on
+	text     string
+	err      error
+}
+
+// start begins generating text for prompt in the background
+func (c *llmClient) start(prompt string, seed int64) *llmJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &llmJob{seed: seed, cancel: cancel, done: make(chan struct{})}
+
+	update := func() {
+		j.mu.Lock()
+		onUpdate := j.onUpdate
+		j.mu.Unlock()
+
+		if onUpdate != nil {
+			onUpdate()
+		}
+	}
+
+	go func() {
+		text, err := c.generate(ctx, prompt, seed, func(token string) {
+			j.mu.Lock()
+			j.streamed.WriteString(token)
+			j.mu.Unlock()
+			update()
+		})
+
+		j.text, j.err = text, err
+		close(j.done)
+		update()
+	}()
+
+	return j
+}
+
+// waitOnScreen waits for j to finish, showing its output stream in on a
+// loading screen if it isn't done yet. Esc cancels the job, returning
+// errCancelled, as does ctx being done, returning its error.
+func waitOnScreen(ctx context.Context, scr tcell.Screen, model string, j *llmJob) (string, error) {
+	select {
+	case <-j.done:
+		return j.text, j.err
+	default:
+	}
+
+	j.mu.Lock()
+	j.onUpdate = func() { scr.PostEvent(nil) }
+	j.mu.Unlock()
+	stop := context.AfterFunc(ctx, func() { scr.PostEvent(nil) })
+
+	defer func() {
+		stop()
+		j.mu.Lock()
+		j.onUpdate = nil
+		j.mu.Unlock()
+		scr.Clear()
+	}()
+
+	for {
+		select {
+		case <-j.done:
+			return j.text, j.err
+		case <-ctx.Done():
+			j.cancel()
+			<-j.done
+			return "", ctx.Err()
+		default:
+		}
+
+		j.mu.Lock()
+		streamed := j.streamed.String()
+		j.mu.Unlock()
+
+		sw, _ := scr.Size()
+		msg := fmt.Sprintf("Generating text with %s...\n\n%s\n\n(esc to cancel)", model, wordWrap(strings.Join(strings.Fields(streamed), " "), min(60, sw-4)))
+
+		scr.Clear()
+		drawStringAtCenter(scr, msg, tcell.StyleDefault)
+		scr.HideCursor()
+		scr.Show()
+
+		if key, ok := scr.PollEvent().(*tcell.EventKey); ok && (key.Key() == tcell.KeyEscape || key.Key() == tcell.KeyCtrlC) {
+			j.cancel()
+			<-j.done
+			return "", errCancelled
+		}
+	}
+}
+
+// llmSource asks a model for new text on every test, passing the seed on so
+// the same model generates the same text for it. The text of the seeds passed
+// to Prefetch is generated in the background, and text which isn't ready when
+// asked for streams in on a loading screen.
+type llmSource struct {
+	testtype string // words or quote
+	opts     sourceOptions
+	client   *llmClient
+	prompt   string
+	next     []*llmJob // 预取, text being generated for the seeds asked for next
+}
+
+// newLLMSource returns the constructor of the llm source of testtype
+func newLLMSource(testtype string) func(o sourceOptions) (Source, error) {
+	return func(o sourceOptions) (Source, error) {
+		if o.Arg == "" {
+			return nil, fmt.Errorf("the llm sources need a model, as in wllm:llama3")
+		}
+
+		opts := sourceOptions{Arg: o.Arg, Language: o.Language, OllamaHost: o.OllamaHost, LLMTimeout: o.LLMTimeout}
+		if testtype == "words" {
+			opts.NumWords, opts.Mods = o.NumWords, o.Mods
+		}
+
+		return &llmSource{
+			testtype: testtype,
+			opts:     opts,
+			client:   newLLMClient(o.Arg, o.OllamaHost, o.LLMTimeout),
+			prompt:   llmPrompt(testtype, o.NumWords, o.Language),
+		}, nil
+	}
+}
+
+func (s *llmSource) Name() string {
+	if s.testtype == "quote" {
+		return "qllm"
+	}
+	return "wllm"
+}
+
+func (s *llmSource) Options() sourceOptions { return s.opts }
+func (s *llmSource) Meta() sourceMeta       { return sourceMeta{Source: s.opts.Arg} }
+
+func (s *llmSource) Prefetch(seeds ...int64) {
+	var next []*llmJob
+	for _, job := range s.next {
+		if slices.Contains(seeds, job.seed) {
+			next = append(next, job)
+		} else {
+			job.cancel()
+		}
+	}
+
+	for _, seed := range seeds {
+		if !slices.ContainsFunc(next, func(job *llmJob) bool { return job.seed == seed }) {
+			next = append(next, s.client.start(s.prompt, seed))
+		}
+	}
+	s.next = next
+}
+
+func (s *llmSource) Next(ctx context.Context, scr tcell.Screen, seed int64) ([]segment, error) {
+	var job *llmJob
+	if i := slices.IndexFunc(s.next, func(job *llmJob) bool { return job.seed == seed }); i != -1 {
+		job = s.next[i]
+		s.next = slices.Delete(s.next, i, i+1)
+	} else {
+		job = s.client.start(s.prompt, seed)
+	}
+
+	text, err := waitOnScreen(ctx, scr, s.client.Model, job)
+	if err == nil && text == "" {
+		err = fmt.Errorf("%s returned no text", s.client.Model)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.testtype == "words" {
+		text = strings.Join(s.opts.Mods.apply(strings.Fields(text), rand.New(rand.NewSource(seed))), " ")
+	}
+	return []segment{{Text: text, Attribution: s.client.Model}}, nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/xyproto/ollamaclient"
+)
+
+// ollamaStub serves /api/generate like Ollama, streaming tokens one line at
+// a time, followed by the seed asked for
+type ollamaStub struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []ollamaclient.GenerateRequest
+}
+
+func (s *ollamaStub) seeds() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var seeds []int
+	for _, req := range s.requests {
+		seeds = append(seeds, req.Options.Seed)
+	}
+	return seeds
+}
+
+func stubOllama(t *testing.T, tokens []string, delay time.Duration) *ollamaStub {
+	s := &ollamaStub{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var req ollamaclient.GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request: %s", err)
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.mu.Unlock()
+
+		if req.Model != "llama3" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"model '%s' not found"}`, req.Model)
+			return
+		}
+
+		tokens := append(tokens, fmt.Sprintf(" %d", req.Options.Seed))
+		for i, token := range tokens {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(delay):
+			}
+
+			b, _ := json.Marshal(ollamaclient.GenerateResponse{Model: req.Model, Response: token, Done: i == len(tokens)-1})
+			fmt.Fprintf(w, "%s\n", b)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestLLMGenerate(t *testing.T) {
+	srv := stubOllama(t, []string{" the", " quick", " \"brown\" fox"}, 0)
+
+	var streamed []string
+	prompt := `Say "hi"`
+	text, err := newLLMClient("llama3", srv.URL, time.Second).generate(context.Background(), prompt, 42, func(token string) {
+		streamed = append(streamed, token)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `the quick "brown" fox 42`; text != want {
+		t.Errorf("text = %q, want %q", text, want)
+	}
+	if len(streamed) != 4 {
+		t.Errorf("streamed %q, want 4 tokens", streamed)
+	}
+	if req := srv.requests[0]; req.Prompt != prompt || req.Options.Seed != 42 {
+		t.Errorf("prompt, seed = %q, %d, want %q, 42", req.Prompt, req.Options.Seed, prompt)
+	}
+}
+
+func TestLLMGenerateErrors(t *testing.T) {
+	srv := stubOllama(t, []string{"slow", "words"}, 200*time.Millisecond)
+
+	tests := []struct {
+		name    string
+		model   string
+		host    string
+		timeout time.Duration
+		want    string
+	}{
+		{"missing model", "nope", srv.URL, time.Second, "model 'nope' not found"},
+		{"timeout", "llama3", srv.URL, 50 * time.Millisecond, "longer than 50ms"},
+		{"unreachable", "llama3", "127.0.0.1:1", time.Second, "can't reach the ollama server at http://127.0.0.1:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newLLMClient(tt.model, tt.host, tt.timeout).generate(context.Background(), "hi", 1, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLLMPrefetch(t *testing.T) {
+	sim := tcell.NewSimulationScreen("UTF-8")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A step asks for the text of next, or prefetches the seeds of prefetch
+	type step struct {
+		next     int64
+		prefetch []int64
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+		want  []int // seeds requested from the server, in order
+	}{
+		{
+			name:  "tests",
+			steps: []step{{next: 7}, {prefetch: []int64{8}}, {next: 8}, {prefetch: []int64{9}}},
+			want:  []int{7, 8, 9},
+		},
+		{
+			// Text is streamed in with seeds of its own while the next test is prefetched
+			name: "time mode",
+			steps: []step{
+				{next: 7}, {prefetch: []int64{101, 8}},
+				{next: 101}, {prefetch: []int64{102, 8}},
+				{next: 102}, {prefetch: []int64{103, 8}},
+				{next: 8},
+			},
+			want: []int{7, 8, 101, 102, 103},
+		},
+		{
+			name:  "not prefetched",
+			steps: []step{{next: 3}},
+			want:  []int{3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := stubOllama(t, []string{"hello"}, 10*time.Millisecond)
+			src, err := newSource("wllm:llama3", sourceOptions{Language: "english", NumWords: 1, OllamaHost: srv.URL, LLMTimeout: time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, step := range tt.steps {
+				if step.prefetch != nil {
+					src.(prefetcher).Prefetch(step.prefetch...)
+					continue
+				}
+
+				test, err := src.Next(context.Background(), sim, step.next)
+				if err != nil {
+					t.Fatalf("Next(%d): %s", step.next, err)
+				}
+				if got, want := test[0].Text, fmt.Sprintf("hello %d", step.next); got != want {
+					t.Errorf("Next(%d) = %q, want %q", step.next, got, want)
+				}
+			}
+
+			// Every seed is requested once, prefetched text is used
+			time.Sleep(50 * time.Millisecond)
+			got := srv.seeds()
+			slices.Sort(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("requested seeds %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
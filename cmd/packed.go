@@ -1,24 +1,109 @@
+// Word lists, quotes and themes, looked up in the user directories before the
+// files packed into the binary
+
 package main
 
-import "encoding/base64"
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 
-func readPackedFile(path string) []byte {
-	if b, ok := packedFiles[path]; !ok {
-		return nil
-	} else {
-		b, err := base64.StdEncoding.DecodeString(b)
-		if err != nil {
-			panic(err)
+	assets "gotype"
+)
+
+const packedLayer = "built in" // 内置, name of the layer of packed files
+
+// dataLayer is one of the places word lists, quotes and themes are found in.
+// Each has a words/, quotes/ and themes/ directory.
+type dataLayer struct {
+	name string // 层名, shown by -list, the directory or built in
+	fsys fs.FS
+}
+
+// packedFS serves the packed files under the names they have in the user
+// directories, words/ and quotes/ being under data/ in the repository.
+type packedFS struct{}
+
+func (packedFS) Open(name string) (fs.File, error) {
+	if name != "themes" && !strings.HasPrefix(name, "themes/") {
+		name = path.Join("data", name)
+	}
+	return assets.Files.Open(name)
+}
+
+// dataLayers returns the layers in the order they are searched,
+// $XDG_CONFIG_HOME/gotype, $XDG_DATA_HOME/gotype and then the packed files.
+func dataLayers() []dataLayer {
+	var layers []dataLayer
+	for _, dir := range []func() (string, error){configDir, dataDir} {
+		if dir, err := dir(); err == nil {
+			layers = append(layers, dataLayer{name: dir, fsys: os.DirFS(dir)})
+		}
+	}
+	return append(layers, dataLayer{name: packedLayer, fsys: packedFS{}})
+}
+
+// path returns where the layer keeps name, for messages about the file.
+// Packed files go by name.
+func (l dataLayer) path(name string) string {
+	if l.name == packedLayer {
+		return name
+	}
+	return filepath.Join(l.name, filepath.FromSlash(name))
+}
+
+// readDataFile reads name, such as themes/default.txt, from the first layer
+// which has it, and returns the path it was read from.
+func readDataFile(name string) ([]byte, string, error) {
+	for _, layer := range dataLayers() {
+		b, err := fs.ReadFile(layer.fsys, name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return b, layer.path(name), err
 		}
+	}
+	return nil, name, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
 
-		return b
+// dataFileExists reports whether any layer has name
+func dataFileExists(name string) bool {
+	for _, layer := range dataLayers() {
+		if _, err := fs.Stat(layer.fsys, name); err == nil {
+			return true
+		}
 	}
+	return false
+}
+
+// dataFile is a file of a data directory, and the layer it is read from
+type dataFile struct {
+	Name  string
+	Layer string
 }
 
-var packedFiles = map[string]string{
-	"./data//words/english_10k.json": "",
-	"./data//words/english_5k.json":  "",
-	"./data//words/english_1k.json":  "",
-	"./data//quotes/english.json":    "",
-	"./themes/default.txt":           "",
+// listDataDir returns the files in dir across the layers, in order. A file
+// in more than one layer is listed once, with the layer it is read from.
+func listDataDir(dir string) []dataFile {
+	seen := map[string]bool{}
+	var files []dataFile
+	for _, layer := range dataLayers() {
+		entries, err := fs.ReadDir(layer.fsys, dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || seen[entry.Name()] {
+				continue
+			}
+			seen[entry.Name()] = true
+			files = append(files, dataFile{Name: entry.Name(), Layer: layer.name})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files
 }
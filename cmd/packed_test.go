@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDataLayers(t *testing.T) {
+	config, data := t.TempDir(), t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("XDG_DATA_HOME", data)
+
+	write := func(dir string, name string, text string) {
+		path := filepath.Join(dir, "gotype", name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Nothing in the user directories, the packed theme is used
+	packed, file, err := readDataFile("themes/default.txt")
+	if err != nil || len(packed) == 0 || file != "themes/default.txt" {
+		t.Fatalf("readDataFile = %q, %s, %v, want the packed theme", packed, file, err)
+	}
+
+	// The data directory overrides the packed files, the config directory both
+	write(data, "themes/default.txt", "data")
+	write(data, "themes/mine.txt", "data")
+	write(config, "themes/mine.txt", "config")
+	write(data, "words/english_1k.json", `{"words": ["hi"]}`)
+
+	if b, file, _ := readDataFile("themes/default.txt"); string(b) != "data" || file != filepath.Join(data, "gotype", "themes", "default.txt") {
+		t.Errorf("default theme = %q from %s, want the one in the data directory", b, file)
+	}
+	if b, file, _ := readDataFile("themes/mine.txt"); string(b) != "config" || file != filepath.Join(config, "gotype", "themes", "mine.txt") {
+		t.Errorf("mine theme = %q from %s, want the one in the config directory", b, file)
+	}
+	if !dataFileExists("quotes/english.json") {
+		t.Errorf("packed quotes/english.json doesn't exist")
+	}
+
+	want := []dataFile{
+		{"default.txt", filepath.Join(data, "gotype")},
+		{"mine.txt", filepath.Join(config, "gotype")},
+	}
+	if got := listDataDir("themes"); !reflect.DeepEqual(got, want) {
+		t.Errorf("listDataDir(themes) = %v, want %v", got, want)
+	}
+
+	layers := map[string]string{}
+	for _, pack := range listPacks(wordsDir) {
+		layers[pack.Name] = pack.Layer
+	}
+	if layers["english_1k"] != filepath.Join(data, "gotype") || layers["english_5k"] != packedLayer {
+		t.Errorf("word list layers = %v", layers)
+	}
+
+	if _, _, err := readDataFile("themes/nope.txt"); !os.IsNotExist(err) {
+		t.Errorf("err = %v, want it not to exist", err)
+	}
+}
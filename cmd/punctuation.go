@@ -0,0 +1,107 @@
+// Punctuation and numbers modifiers for word tests
+
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// textModifiers change the words of a test the way MonkeyType's punctuation
+// and numbers options do
+type textModifiers struct {
+	Punctuation bool // 标点, capitalise sentences and add punctuation
+	Numbers     bool // 数字, mix numbers in with the words
+}
+
+const numberRate = 0.1 // share of words replaced by a number
+
+// capitalise upper cases the first letter of word
+func capitalise(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	if r == utf8.RuneError {
+		return word
+	}
+	return string(unicode.ToUpper(r)) + word[size:]
+}
+
+// randomNumber returns a number of one to four digits
+func randomNumber(rng *rand.Rand) string {
+	digits := rng.Intn(4) + 1
+	n := rng.Intn(9) + 1
+	for i := 1; i < digits; i++ {
+		n = n*10 + rng.Intn(10)
+	}
+	return strconv.Itoa(n)
+}
+
+// apply returns words with the modifiers applied. With punctuation, sentences
+// start with a capital and end with a full stop, question or exclamation mark,
+// and commas, quotes, parentheses, colons and hyphenated words turn up in
+// between at roughly the rate they do in prose.
+func (m textModifiers) apply(words []string, rng *rand.Rand) []string {
+	if !m.Punctuation && !m.Numbers {
+		return words
+	}
+
+	out := make([]string, 0, len(words))
+	sentenceStart := true
+	hyphenate := false
+
+	for i, w := range words {
+		if m.Numbers && rng.Float64() < numberRate {
+			w = randomNumber(rng)
+		}
+
+		if !m.Punctuation {
+			out = append(out, w)
+			continue
+		}
+
+		if sentenceStart {
+			w = capitalise(w)
+			sentenceStart = false
+		}
+
+		last := i == len(words)-1
+		switch r := rng.Float64(); {
+		case last:
+			w += "."
+		case r < 0.08:
+			w += "."
+			sentenceStart = true
+		case r < 0.10:
+			w += "?"
+			sentenceStart = true
+		case r < 0.11:
+			w += "!"
+			sentenceStart = true
+		case r < 0.21:
+			w += ","
+		case r < 0.23:
+			w = "\"" + w + "\""
+		case r < 0.25:
+			w = "(" + w + ")"
+		case r < 0.26:
+			w += ":"
+		case r < 0.27:
+			w += ";"
+		case r < 0.29 && !hyphenate:
+			// Joined onto the next word
+			hyphenate = true
+			out = append(out, w)
+			continue
+		}
+
+		if hyphenate {
+			hyphenate = false
+			out[len(out)-1] += "-" + w
+			continue
+		}
+		out = append(out, w)
+	}
+
+	return out
+}
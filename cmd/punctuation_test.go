@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestTextModifiers(t *testing.T) {
+	words := strings.Fields(strings.Repeat("the of and to a in is you that it ", 4))
+
+	// bare strips the punctuation around a word, leaving its letters, digits
+	// and hyphens
+	bare := func(w string) string {
+		return strings.TrimFunc(w, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	}
+	isNumber := func(w string) bool {
+		return w != "" && strings.Trim(w, "0123456789") == ""
+	}
+
+	tests := []struct {
+		name  string
+		mods  textModifiers
+		check func(t *testing.T, out []string)
+	}{
+		{
+			name: "ends a sentence",
+			mods: textModifiers{Punctuation: true},
+			check: func(t *testing.T, out []string) {
+				if last := out[len(out)-1]; !strings.ContainsAny(last[len(last)-1:], ".?!") {
+					t.Errorf("last word %q doesn't end with . ? or !", last)
+				}
+			},
+		},
+		{
+			name: "capitalises sentences",
+			mods: textModifiers{Punctuation: true},
+			check: func(t *testing.T, out []string) {
+				prev := "."
+				for _, w := range out {
+					if strings.ContainsAny(prev[len(prev)-1:], ".?!") && !unicode.IsUpper([]rune(bare(w))[0]) {
+						t.Errorf("%q starts a sentence after %q but isn't capitalised", w, prev)
+					}
+					prev = w
+				}
+			},
+		},
+		{
+			name: "joins hyphenated pairs",
+			mods: textModifiers{Punctuation: true},
+			check: func(t *testing.T, out []string) {
+				// Every word is still there, in order, hyphenated pairs
+				// counting as two
+				var got []string
+				for _, w := range out {
+					for _, part := range strings.Split(bare(w), "-") {
+						got = append(got, strings.ToLower(bare(part)))
+					}
+				}
+				if strings.Join(got, " ") != strings.Join(words, " ") {
+					t.Errorf("words %q, want %q", got, words)
+				}
+			},
+		},
+		{
+			name: "numbers alone",
+			mods: textModifiers{Numbers: true},
+			check: func(t *testing.T, out []string) {
+				if len(out) != len(words) {
+					t.Fatalf("%d words, want %d", len(out), len(words))
+				}
+				for i, w := range out {
+					if w != words[i] && !isNumber(w) {
+						t.Errorf("word %d is %q, want %q or a number", i, w, words[i])
+					}
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hyphens := 0
+			for seed := int64(0); seed < 200; seed++ {
+				out := tt.mods.apply(words, rand.New(rand.NewSource(seed)))
+				tt.check(t, out)
+
+				hyphens += strings.Count(strings.Join(out, " "), "-")
+				if t.Failed() {
+					t.Fatalf("seed %d: %q", seed, out)
+				}
+			}
+
+			// The seeds are enough to get hyphenated pairs
+			if tt.mods.Punctuation && hyphens == 0 {
+				t.Errorf("no hyphenated words in 200 seeds")
+			}
+		})
+	}
+}
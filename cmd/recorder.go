@@ -0,0 +1,135 @@
+// Record the keystrokes of a test so it can be replayed later
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const recordingVersion = 1 // 版本, of recording
+
+// Key event kinds
+const (
+	keyRune       = "rune"       // a character was typed
+	keyBackspace  = "backspace"  // the previous character was deleted
+	keyDeleteWord = "deleteword" // the previous word was deleted (ctrl+w, alt/ctrl+backspace)
+	keySkip       = "skip"       // space was pressed inside a word, skipping the rest of it
+)
+
+// keyEvent is a single recorded key press
+type keyEvent struct {
+	Time    time.Duration `json:"time"`           // 时间, since the recording started
+	Kind    string        `json:"kind"`           // 类型, one of the key event kinds
+	Rune    rune          `json:"rune,omitempty"` // 字符, the typed character for keyRune
+	Segment int           `json:"segment"`        // 段落, index of the segment being typed
+	Pos     int           `json:"pos"`            // 位置, rune offset into the segment text before the event
+}
+
+// 录制文件
+// 格式为
+//
+//	{
+//	  "version": 1,
+//	  "segments": [{"text": "the of to", "attribution": "english_1k"}],
+//	  "timeout": -1,
+//	  "skipword": true,
+//	  "code": false,
+//	  "events": [
+//	    {"time": 1534000000, "kind": "rune", "rune": 116, "segment": 0, "pos": 0},
+//	    ...
+//	  ]
+//	}
+type recording struct {
+	Version    int        `json:"version"`
+	Segments   []segment  `json:"segments"`
+	Timeout    int        `json:"timeout"`              // time limit in seconds, -1 for none
+	SkipWord   bool       `json:"skipword"`             // whether space skipped words while recording
+	Code       bool       `json:"code,omitempty"`       // typed in code mode
+	TypeIndent bool       `json:"typeindent,omitempty"` // code mode indentation was typed
+	TabWidth   int        `json:"tabwidth,omitempty"`   // spaces typed by tab in code mode
+	Events     []keyEvent `json:"events"`
+}
+
+// recorder logs the key events of a test, along with the text typed. A nil
+// recorder records nothing.
+type recorder struct {
+	now      func() time.Time // 时钟, the clock of the test
+	start    time.Time
+	segment  int
+	segments []segment
+	events   []keyEvent
+}
+
+// reset discards any recorded events and restarts the clock for a test of
+// the given segments, timed by now
+func (r *recorder) reset(segments []segment, now func() time.Time) {
+	if r == nil {
+		return
+	}
+
+	r.now = now
+	r.start = now()
+	r.segment = 0
+	r.segments = append([]segment(nil), segments...)
+	r.events = nil
+}
+
+// extend records text streamed onto the end of the current segment
+func (r *recorder) extend(text string) {
+	if r == nil {
+		return
+	}
+
+	r.segments[r.segment].Text += text
+}
+
+func (r *recorder) record(kind string, c rune, pos int) {
+	if r == nil {
+		return
+	}
+
+	r.events = append(r.events, keyEvent{
+		Time:    r.now().Sub(r.start),
+		Kind:    kind,
+		Rune:    c,
+		Segment: r.segment,
+		Pos:     pos,
+	})
+}
+
+func recordingsDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "recordings"), nil
+}
+
+// saveRecording writes rec to the recordings directory and returns the id it
+// was saved under.
+func saveRecording(rec recording) (string, error) {
+	dir, err := recordingsDir()
+	if err != nil {
+		return "", err
+	}
+
+	// A second recording saved in the same second gets -2, -3, ... added to
+	// its id rather than replacing the first
+	rec.Version = recordingVersion
+	base := time.Now().Format("20060102-150405")
+	for n := 1; ; n++ {
+		id := base
+		if n > 1 {
+			id = fmt.Sprintf("%s-%d", base, n)
+		}
+
+		err := writeJSONFile(filepath.Join(dir, id+".json"), rec, false)
+		if !os.IsExist(err) {
+			return id, err
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestSaveRecordingIDs(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	ids := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		id, err := saveRecording(recording{Segments: []segment{{Text: "hi"}}, Timeout: i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids[id] {
+			t.Fatalf("id %s saved twice", id)
+		}
+		ids[id] = true
+
+		rec, err := loadRecording(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Timeout != i {
+			t.Errorf("recording %s has timeout %d, want %d", id, rec.Timeout, i)
+		}
+	}
+}
+
+// A recorded test replays to the same stats and mistakes
+func TestReplayRoundTrip(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	const timeout = 3 * time.Second
+	text := []segment{{Text: "the quick brown fox jumps over"}}
+
+	// A typo fixed with backspace, a word deleted with ctrl+w, a word skipped
+	// and the rest left to time out, 3s after the first key
+	g := newTestGoType(script(
+		typeKeys("thw"), press(tcell.KeyBackspace2, tcell.ModNone), typeKeys("e qu"),
+		press(tcell.KeyCtrlW, tcell.ModCtrl), typeKeys("quick b fox ju"),
+		wait(timeout-22*keyInterval),
+	))
+	g.Recorder = &recorder{}
+
+	_, _, dur, rc, mistakes, stats := g.StartTest(text, timeout)
+	if rc != GoTypeComplete {
+		t.Fatalf("rc = %d, want %d", rc, GoTypeComplete)
+	}
+
+	id, err := saveRecording(recording{
+		Segments: g.Recorder.segments,
+		Timeout:  int(timeout / time.Second),
+		SkipWord: g.SkipWord,
+		Events:   g.Recorder.events,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := loadRecording(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every key is delivered as soon as it is due, up to the time limit
+	rg := newTestGoType(nil)
+	r := newReplayer(rg.scr, rec, 1)
+	r.rewind(rec.Events[0].Time + timeout)
+
+	rdur, rrc, rmistakes, rstats := r.run(rg, rec)
+	if rrc != GoTypeComplete {
+		t.Fatalf("replay rc = %d, want %d", rrc, GoTypeComplete)
+	}
+	if rdur != dur {
+		t.Errorf("replay duration = %s, want %s", rdur, dur)
+	}
+	if !reflect.DeepEqual(rmistakes, mistakes) {
+		t.Errorf("replay mistakes = %v, want %v", rmistakes, mistakes)
+	}
+	if !reflect.DeepEqual(rstats, stats) {
+		t.Errorf("replay stats = %+v, want %+v", rstats, stats)
+	}
+	if len(mistakes) == 0 || stats.Corrected == 0 || len(stats.Samples) != 3 {
+		t.Errorf("mistakes, corrected, samples = %v, %d, %d, want a skipped word, a corrected typo and 3 samples", mistakes, stats.Corrected, len(stats.Samples))
+	}
+}
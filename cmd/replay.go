@@ -0,0 +1,293 @@
+// Play recorded tests back through the typing engine
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gdamore/tcell"
+)
+
+const (
+	replaySeekStep = 5 * time.Second       // 每次跳转的时间, how far the arrow keys seek
+	replayTick     = 50 * time.Millisecond // how often the screen is redrawn while waiting for the next key
+	replayMinSpeed = 0.25
+	replayMaxSpeed = 16
+)
+
+// loadRecording reads a recording either from the given file or, if no such
+// file exists, from the recordings directory by id.
+func loadRecording(idOrFile string) (*recording, error) {
+	path := idOrFile
+	if _, err := os.Stat(path); err != nil {
+		dir, err := recordingsDir()
+		if err != nil {
+			return nil, err
+		}
+
+		path = filepath.Join(dir, idOrFile+".json")
+	}
+
+	var rec recording
+	err := readJSONFile(path, "recording", recordingVersion, &rec)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s is neither a recording file nor the id of a recording, run a test with -record and see -history for ids", idOrFile)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(rec.Segments) == 0 {
+		return nil, fmt.Errorf("%s does not contain any text", path)
+	}
+
+	return &rec, nil
+}
+
+// replayer feeds recorded key events to the engine in place of the keyboard,
+// on a virtual clock which can be paused, sped up and moved around. Keys
+// pressed by the user control the replay instead of being typed.
+type replayer struct {
+	scr    tcell.Screen
+	events []keyEvent
+	end    time.Duration // time of the last event
+
+	base   time.Time     // the virtual clock reads base+clock
+	clock  time.Duration // 虚拟时间, position in the recording
+	last   time.Time     // real time the clock was last advanced
+	next   int           // index of the next event to deliver
+	target time.Duration // events up to target are delivered without waiting
+
+	speed   float64
+	paused  bool
+	restart bool // rewind to target, the engine has to start over
+	quit    bool
+}
+
+func newReplayer(scr tcell.Screen, rec *recording, speed float64) *replayer {
+	r := &replayer{
+		scr:    scr,
+		events: rec.Events,
+		base:   time.Now(),
+		speed:  speed,
+	}
+
+	if len(r.events) > 0 {
+		r.end = r.events[len(r.events)-1].Time
+	}
+
+	r.rewind(0)
+	return r
+}
+
+// rewind moves back to the start of the recording, after which every event
+// up to target is delivered immediately.
+func (r *replayer) rewind(target time.Duration) {
+	r.next = 0
+	r.clock = 0
+	r.target = target
+	r.last = time.Now()
+	r.restart = false
+
+	// Skip the idle time before the first key press
+	if len(r.events) > 0 && r.events[0].Time > r.target {
+		r.target = r.events[0].Time
+	}
+}
+
+func (r *replayer) now() time.Time {
+	return r.base.Add(r.clock)
+}
+
+// advance moves the virtual clock forward by the real time passed since it
+// was last advanced.
+func (r *replayer) advance() {
+	now := time.Now()
+	if !r.paused && r.clock >= r.target {
+		r.clock += time.Duration(float64(now.Sub(r.last)) * r.speed)
+	}
+	r.last = now
+}
+
+// keyEventToTcell converts a recorded event back into a key press the
+// engine handles the same way.
+func keyEventToTcell(ev keyEvent) *tcell.EventKey {
+	switch {
+	case ev.Kind == keyBackspace:
+		return tcell.NewEventKey(tcell.KeyBackspace2, 0, tcell.ModNone)
+	case ev.Kind == keyDeleteWord:
+		return tcell.NewEventKey(tcell.KeyCtrlW, 0, tcell.ModCtrl)
+	case ev.Kind == keySkip:
+		return tcell.NewEventKey(tcell.KeyRune, ' ', tcell.ModNone)
+	case ev.Rune == '\n': // enter, in code mode
+		return tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone)
+	default:
+		return tcell.NewEventKey(tcell.KeyRune, ev.Rune, tcell.ModNone)
+	}
+}
+
+// PollEvent returns the next recorded key press once it is due
+func (r *replayer) PollEvent() tcell.Event {
+	for {
+		r.advance()
+
+		if r.quit {
+			return tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone)
+		}
+
+		if r.restart {
+			return tcell.NewEventResize(r.scr.Size())
+		}
+
+		if r.next < len(r.events) {
+			ev := r.events[r.next]
+
+			// Catching up after a seek, jump straight to the event
+			if ev.Time <= r.target && r.clock < ev.Time {
+				r.clock = ev.Time
+			}
+
+			if ev.Time <= r.clock {
+				r.next++
+				return keyEventToTcell(ev)
+			}
+		}
+
+		if r.clock < r.target {
+			r.clock = r.target
+		}
+
+		r.drawStatus()
+
+		// Wake up in time for the next event, or to redraw the timer
+		wait := replayTick
+		if r.next < len(r.events) && !r.paused {
+			if d := time.Duration(float64(r.events[r.next].Time-r.clock) / r.speed); d < wait {
+				wait = d
+			}
+		}
+
+		timer := time.AfterFunc(wait, func() { r.scr.PostEvent(nil) })
+		ev := r.scr.PollEvent()
+		timer.Stop()
+
+		switch ev := ev.(type) {
+		case *tcell.EventKey:
+			r.control(ev)
+		case *tcell.EventResize:
+			r.restart = true
+			r.target = r.clock
+		default: // tick
+			return nil
+		}
+	}
+}
+
+// PostEventWait passes ev on to the screen, where PollEvent picks it up
+func (r *replayer) PostEventWait(ev tcell.Event) {
+	r.scr.PostEventWait(ev)
+}
+
+// control handles a key pressed by the user during the replay
+func (r *replayer) control(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyEscape, tcell.KeyCtrlC:
+		r.quit = true
+	case tcell.KeyRight:
+		r.target = r.clock + replaySeekStep
+	case tcell.KeyLeft:
+		target := r.clock - replaySeekStep
+		if target < 0 {
+			target = 0
+		}
+		r.restart = true
+		r.target = target
+	case tcell.KeyUp:
+		if r.speed < replayMaxSpeed {
+			r.speed *= 2
+		}
+	case tcell.KeyDown:
+		if r.speed > replayMinSpeed {
+			r.speed /= 2
+		}
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case ' ':
+			r.paused = !r.paused
+		case 'q':
+			r.quit = true
+		case '+':
+			r.control(tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone))
+		case '-':
+			r.control(tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone))
+		}
+	}
+}
+
+// drawStatus draws the replay position and controls on the bottom line
+func (r *replayer) drawStatus() {
+	state := "playing"
+	if r.paused {
+		state = "paused "
+	}
+
+	pos := r.clock
+	if pos > r.end {
+		pos = r.end
+	}
+
+	status := fmt.Sprintf(" %s %5gx  %5.1fs / %.1fs   space pause  ←/→ seek  ↑/↓ speed  esc quit ",
+		state, r.speed, pos.Seconds(), r.end.Seconds())
+
+	sw, sh := r.scr.Size()
+	drawString(r.scr, (sw-len([]rune(status)))/2, sh-1, status, -1, tcell.StyleDefault.Reverse(true))
+	r.scr.Show()
+}
+
+// run plays rec back on t until the test is over, starting it again from
+// the target when the replay is rewound
+func (r *replayer) run(t *gotype, rec *recording) (dur time.Duration, rc int, mistakes []mistake, stats testStats) {
+	t.Events = r
+	t.Now = r.now
+	t.Recorder = nil
+	t.Stream = nil // streamed text is already in the recorded segments
+	t.SkipWord = rec.SkipWord
+	t.Code, t.TypeIndent, t.TabWidth = rec.Code, rec.TypeIndent, rec.TabWidth
+	t.DisableBackspace = false
+
+	timeout := time.Duration(-1)
+	if rec.Timeout != -1 {
+		timeout = time.Duration(rec.Timeout) * time.Second
+	}
+
+	for {
+		_, _, dur, rc, mistakes, stats = t.StartTest(rec.Segments, timeout)
+		if rc != GoTypeResize {
+			return
+		}
+
+		r.rewind(r.target)
+	}
+}
+
+// replay plays rec back on t until it completes or the user quits
+func replay(t *gotype, rec *recording, speed float64) {
+	r := newReplayer(t.scr, rec, speed)
+
+	dur, rc, mistakes, stats := r.run(t, rec)
+	if rc != GoTypeComplete {
+		return
+	}
+
+	attribution := ""
+	if len(rec.Segments) == 1 {
+		attribution = rec.Segments[0].Attribution
+	}
+	res := newResult(stats, dur, mistakes)
+	if len(rec.Segments) == 1 {
+		res.QuoteLength = rec.Segments[0].Group
+	}
+	showReport(t.scr, res, attribution)
+}
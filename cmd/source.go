@@ -0,0 +1,126 @@
+// Sources of the text of typing tests, and the registry they are found in
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell"
+)
+
+// errCancelled is returned by Next when the user gives up waiting on text
+var errCancelled = errors.New("cancelled")
+
+// Source gives the text of typing tests. Each call to Next is a new test, and
+// the same seed gives the same text. scr is the screen the test is typed on,
+// for text laid out to fit it.
+type Source interface {
+	Name() string           // 名称, what results are recorded under, e.g. words
+	Options() sourceOptions // 选项, the options the source uses, those it doesn't are left zero
+	Meta() sourceMeta       // 元数据, describes the text
+	Next(ctx context.Context, scr tcell.Screen, seed int64) ([]segment, error)
+}
+
+// prefetcher is a Source which can get text ready before it is asked for it
+type prefetcher interface {
+	// Prefetch starts on the text of seeds in the background, dropping text
+	// started earlier for any other seed.
+	Prefetch(seeds ...int64)
+}
+
+// sourceOptions are what a source is created with, taken from the flags
+type sourceOptions struct {
+	Arg         string        // 参数, word list, quote pack, model or file to take the text from
+	Language    string        // 语言, language of the default list and of generated text
+	NumWords    int           // words per segment
+	NumSegments int           // segments per test
+	Mods        textModifiers // punctuation and numbers
+	Frequency   float64       // weight of common words, 0 to 1
+	QuoteLength string        // short, medium, long, thicc or all
+	QuoteIDs    []int         // quotes to pick from, nil for every quote
+	OllamaHost  string        // address of the Ollama server
+	LLMTimeout  time.Duration // time the model has to generate text, 0 for no limit
+	TabWidth    int           // spaces a tab in code is shown as
+}
+
+type sourceMeta struct {
+	Source string // 来源, recorded with results, the list, pack, model or file the text comes from
+	Code   bool   // the text is code, typed line by line with its indentation
+}
+
+type sourceFactory struct {
+	description string // 描述, shown by -list sources
+	create      func(o sourceOptions) (Source, error)
+}
+
+var sourceRegistry = map[string]sourceFactory{} // 来源注册表, sources by name
+
+// registerSource makes a source available to -source name. Sources register
+// themselves from init.
+func registerSource(name string, description string, create func(o sourceOptions) (Source, error)) {
+	if _, ok := sourceRegistry[name]; ok {
+		panic("source " + name + " registered twice")
+	}
+	sourceRegistry[name] = sourceFactory{description: description, create: create}
+}
+
+// sourceNames returns the names of the registered sources in order
+func sourceNames() []string {
+	names := make([]string, 0, len(sourceRegistry))
+	for name := range sourceRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newSource creates the source spec names. A spec is a source name, followed
+// by a colon and the argument of the source if it takes one, as in
+// quotes:german or wllm:llama3.
+func newSource(spec string, o sourceOptions) (Source, error) {
+	name, arg, found := strings.Cut(spec, ":")
+	if found {
+		o.Arg = arg
+	}
+
+	factory, ok := sourceRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("there is no %s source, use -list sources to see the sources", name)
+	}
+	return factory.create(o)
+}
+
+// printSources lists the registered sources
+func printSources() {
+	for _, name := range sourceNames() {
+		fmt.Printf("%-8s %s\n", name, sourceRegistry[name].description)
+	}
+}
+
+// showSourceError shows err on the screen and returns whether the user wants
+// to try again.
+func showSourceError(scr tcell.Screen, source string, err error) bool {
+	sw, _ := scr.Size()
+	msg := fmt.Sprintf("Error getting text from %s:\n\n%s\n\n(enter to try again, esc to quit)", source, wordWrap(err.Error(), min(60, sw-4)))
+
+	scr.Clear()
+	drawStringAtCenter(scr, msg, tcell.StyleDefault)
+	scr.HideCursor()
+	scr.Show()
+
+	for {
+		if key, ok := scr.PollEvent().(*tcell.EventKey); ok {
+			switch key.Key() {
+			case tcell.KeyEnter:
+				return true
+			case tcell.KeyEscape, tcell.KeyCtrlC:
+				return false
+			}
+		}
+	}
+}
@@ -3,25 +3,92 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"math/rand"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+
+	"github.com/gdamore/tcell"
+)
+
+const (
+	wordsDir  = "words"  // 单词文件, MonkeyType word lists
+	quotesDir = "quotes" // 引用文件, MonkeyType quote packs, one per language
 )
 
-func randomText(words []string, numwords int) string {
+// wordSampler picks random words from a list, never the same word twice in
+// a row. Lists ordered by frequency can be sampled with Zipf weights, where the
+// word of rank r is picked in proportion to 1/r^exponent. An exponent of 0 is
+// uniform and 1 is close to the distribution of words in natural language.
+type wordSampler struct {
+	words      []string
+	cumulative []float64 // 累计权重, running total of the weights, nil when uniform
+	last       int       // index of the previous word, -1 for none
+	rng        *rand.Rand
+}
+
+func newWordSampler(words []string, exponent float64, rng *rand.Rand) *wordSampler {
+	s := &wordSampler{words: words, last: -1, rng: rng}
+	if exponent <= 0 {
+		return s
+	}
+
+	s.cumulative = make([]float64, len(words))
+	total := 0.0
+	for i := range words {
+		total += 1 / math.Pow(float64(i+1), exponent)
+		s.cumulative[i] = total
+	}
+	return s
+}
+
+func (s *wordSampler) pick() int {
+	if s.cumulative == nil {
+		return s.rng.Intn(len(s.words))
+	}
+
+	r := s.rng.Float64() * s.cumulative[len(s.cumulative)-1]
+	i, _ := slices.BinarySearch(s.cumulative, r)
+	return min(i, len(s.words)-1)
+}
+
+func (s *wordSampler) next() string {
+	i := s.pick()
+	for len(s.words) > 1 && i == s.last {
+		i = s.pick()
+	}
+
+	s.last = i
+	return s.words[i]
+}
+
+func randomText(words *wordSampler, numwords int, mods textModifiers) string {
 	// Return random list of numwords from words
 	var returnWords []string
 	for i := 0; i < numwords; i++ {
 		// Append random word to returnWords
-		random_index := rand.Intn(len(words))
-		returnWords = append(returnWords, words[random_index])
+		returnWords = append(returnWords, words.next())
 	}
 
 	// Return the words as a joined string
-	return strings.Join(returnWords, " ")
+	return strings.Join(mods.apply(returnWords, words.rng), " ")
+}
+
+// joinSegments joins the text of every segment into one
+func joinSegments(segments []segment) string {
+	texts := make([]string, len(segments))
+	for i, seg := range segments {
+		texts[i] = seg.Text
+	}
+
+	return strings.Join(texts, " ")
 }
 
 // 从文件中生成单词
@@ -45,45 +112,109 @@ type wordTestFile struct {
 	Words              []string `json:"words"`
 }
 
-func generateWordsTestFromFile(filename string, numwords int, numsegments int) func() []segment {
-	// fmt.Println("Reading from file: " + filename)
-	res, err := os.ReadFile(fmt.Sprintf("./data/words/%s.json", filename))
-	if err != nil {
-		exit("%s does not appear to be a valid word file, use -list words to see a list of supported word lists", filename)
+// wordFileLanguage returns the language of a word list from its name, which
+// follows the MonkeyType convention of <language> or <language>_<size>.
+func wordFileLanguage(name string) string {
+	return strings.SplitN(name, "_", 2)[0]
+}
+
+func packExists(dir string, name string) bool {
+	return dataFileExists(fmt.Sprintf("%s/%s.json", dir, name))
+}
+
+// resolveWordFile finds the word list for -words name in language. No name
+// means the language's default list, <language>_1k or just <language>, and a
+// name which isn't a list of its own is tried as a size, so -words 10k is
+// english_10k.
+func resolveWordFile(name string, language string) string {
+	if name == "" {
+		if packExists(wordsDir, language+"_1k") {
+			return language + "_1k"
+		}
+		return language
 	}
 
-	// Parse the file and get the words
-	name := strings.Split(filename, ".")[0]
+	if !packExists(wordsDir, name) && packExists(wordsDir, language+"_"+name) {
+		return language + "_" + name
+	}
+	return name
+}
 
-	// words := make([]string, 0)
-	var wordTestFile wordTestFile
-	var words []string
-	err = json.Unmarshal(res, &wordTestFile)
+func init() {
+	registerSource("words", "random words from a word list, words:<list>", newWordsSource)
+	registerSource("quotes", "a random quote from a quote pack, quotes:<pack>", newQuotesSource)
+	registerSource("custom", "the text of a file, custom:<file>", newTextSource)
+	registerSource("code", "the source code in a file, code:<file>", newCodeSource)
+}
+
+// wordsSource picks numwords random words per segment from a word list,
+// weighted by frequency if the list is ordered by it.
+type wordsSource struct {
+	opts    sourceOptions
+	sampler *wordSampler
+}
+
+func newWordsSource(o sourceOptions) (Source, error) {
+	filename := resolveWordFile(o.Arg, o.Language)
+	res, _, err := readDataFile(fmt.Sprintf("%s/%s.json", wordsDir, filename))
 	if err != nil {
-		exit("Error parsing word file: %s", err)
+		return nil, fmt.Errorf("%s does not appear to be a valid word file, use -list words to see a list of supported word lists", filename)
 	}
 
-	words = wordTestFile.Words
+	var wordTestFile wordTestFile
+	if err = json.Unmarshal(res, &wordTestFile); err != nil {
+		return nil, fmt.Errorf("error parsing word file: %s", err)
+	}
 
-	// Return a function that
-	return func() []segment {
-		segments := make([]segment, numsegments)
-		for i := 0; i < numsegments; i++ {
-			segments[i] = segment{Text: randomText(words, numwords), Attribution: name}
-		}
-		return segments
+	if len(wordTestFile.Words) == 0 {
+		return nil, fmt.Errorf("%s does not contain any words", filename)
 	}
+
+	frequency := o.Frequency
+	if !wordTestFile.OrderedByFrequency {
+		frequency = 0
+	}
+
+	return &wordsSource{
+		opts: sourceOptions{
+			Arg:         filename,
+			Language:    o.Language,
+			NumWords:    o.NumWords,
+			NumSegments: o.NumSegments,
+			Mods:        o.Mods,
+			Frequency:   frequency,
+		},
+		sampler: newWordSampler(wordTestFile.Words, frequency, nil),
+	}, nil
+}
+
+func (s *wordsSource) Name() string           { return "words" }
+func (s *wordsSource) Options() sourceOptions { return s.opts }
+func (s *wordsSource) Meta() sourceMeta       { return sourceMeta{Source: s.opts.Arg} }
+
+func (s *wordsSource) Next(ctx context.Context, scr tcell.Screen, seed int64) ([]segment, error) {
+	words := *s.sampler
+	words.rng = rand.New(rand.NewSource(seed))
+
+	name := strings.Split(s.opts.Arg, ".")[0]
+	segments := make([]segment, s.opts.NumSegments)
+	for i := range segments {
+		segments[i] = segment{Text: randomText(&words, s.opts.NumWords, s.opts.Mods), Attribution: name}
+	}
+	return segments, nil
 }
 
 type quoteTestFile struct {
-	Language string  `json:"language"`
-	Groups   [][]int `json:"groups"`
-	Quotes   []struct {
-		Text   string `json:"text"`
-		Source string `json:"source"`
-		Length int    `json:"length"`
-		ID     int    `json:"id"`
-	} `json:"quotes"`
+	Language string       `json:"language"`
+	Groups   [][]int      `json:"groups"`
+	Quotes   []quoteEntry `json:"quotes"`
+}
+
+type quoteEntry struct {
+	Text   string `json:"text"`
+	Source string `json:"source"`
+	Length int    `json:"length"`
+	ID     int    `json:"id"`
 }
 
 // 从quotes/选一个
@@ -107,85 +238,347 @@ type quoteTestFile struct {
 //	    ...
 //	  ]
 //	}
-func generateQuoteTestFromFile(filename string) func() []segment {
-	res, err := os.ReadFile(fmt.Sprintf("./data/quotes/%s.json", filename))
+func readQuoteFile(filename string) (quoteTestFile, error) {
+	var quoteTestFile quoteTestFile
+	res, _, err := readDataFile(fmt.Sprintf("%s/%s.json", quotesDir, filename))
 	if err != nil {
-		exit("%s does not appear to be a valid quote file, use -list quotes to see a list of supported quote lists", filename)
+		return quoteTestFile, err
 	}
 
-	// Parse the file and get the quotes
-	var quoteTestFile quoteTestFile
 	err = json.Unmarshal(res, &quoteTestFile)
+	return quoteTestFile, err
+}
+
+// resolveQuoteFile finds the quote pack for -quotes name, either the file of
+// that name, <language>_<name>, or the pack whose language is name.
+func resolveQuoteFile(name string, language string) string {
+	if packExists(quotesDir, name) {
+		return name
+	}
+	if packExists(quotesDir, language+"_"+name) {
+		return language + "_" + name
+	}
+
+	for _, file := range listPacks(quotesDir) {
+		if q, err := readQuoteFile(file.Name); err == nil && q.Language == name {
+			return file.Name
+		}
+	}
+	return name
+}
+
+// quoteLengths names the groups of a quote file, in order
+var quoteLengths = []string{"short", "medium", "long", "thicc"}
+
+// quoteGroup returns the name of the group a quote of length falls into
+func (q quoteTestFile) quoteGroup(length int) string {
+	for i, group := range q.Groups {
+		if i < len(quoteLengths) && len(group) == 2 && length >= group[0] && length <= group[1] {
+			return quoteLengths[i]
+		}
+	}
+	return ""
+}
+
+// quotesSource picks a random quote of the given length group from a quote
+// pack, only from the quotes with the given ids if there are any.
+type quotesSource struct {
+	opts   sourceOptions
+	pack   quoteTestFile
+	quotes []quoteEntry // 可选的引用, the quotes of the chosen length and ids
+}
+
+func newQuotesSource(o sourceOptions) (Source, error) {
+	if o.QuoteLength == "" {
+		o.QuoteLength = "all"
+	}
+	if o.QuoteLength != "all" && !slices.Contains(quoteLengths, o.QuoteLength) {
+		return nil, fmt.Errorf("-quotelength must be one of %s or all", strings.Join(quoteLengths, ", "))
+	}
+
+	filename := o.Arg
+	if filename == "" {
+		filename = o.Language
+	}
+	filename = resolveQuoteFile(filename, o.Language)
+
+	res, _, err := readDataFile(fmt.Sprintf("%s/%s.json", quotesDir, filename))
 	if err != nil {
-		exit("Error parsing quote file: %s", err)
+		return nil, fmt.Errorf("%s does not appear to be a valid quote file, use -list quotes to see a list of supported quote lists", filename)
 	}
 
-	quotes := quoteTestFile.Quotes
+	var quoteTestFile quoteTestFile
+	if err = json.Unmarshal(res, &quoteTestFile); err != nil {
+		return nil, fmt.Errorf("error parsing quote file: %s", err)
+	}
 
-	// Return a function that gets a random quote
-	return func() []segment {
-		var randomIndex = rand.Intn(len(quotes))
-		return []segment{{Text: quotes[randomIndex].Text, Attribution: quotes[randomIndex].Source}}
+	// Keep the quotes of the chosen length
+	quotes := quoteTestFile.Quotes[:0:0]
+	for _, quote := range quoteTestFile.Quotes {
+		if o.QuoteIDs != nil && !slices.Contains(o.QuoteIDs, quote.ID) {
+			continue
+		}
+		if o.QuoteLength == "all" || quoteTestFile.quoteGroup(quote.Length) == o.QuoteLength {
+			quotes = append(quotes, quote)
+		}
+	}
+	if len(quotes) == 0 && o.QuoteLength != "all" {
+		return nil, fmt.Errorf("%s has no %s quotes, try another -quotelength", filename, o.QuoteLength)
+	} else if len(quotes) == 0 {
+		return nil, fmt.Errorf("%s has no such quotes, use -quotesearch to find one", filename)
 	}
 
+	return &quotesSource{
+		opts: sourceOptions{
+			Arg:         filename,
+			Language:    o.Language,
+			QuoteLength: o.QuoteLength,
+			QuoteIDs:    o.QuoteIDs,
+		},
+		pack:   quoteTestFile,
+		quotes: quotes,
+	}, nil
 }
 
-// TODO 用LLM来生成单词
-func generateTestFromLLM(testtype string, llm string, numwords int) func() []segment {
-	// Use go to send a request to the ollama server and get words
-	fmt.Println("Generating words from LLM", testtype)
-	prompt := ""
+func (s *quotesSource) Name() string           { return "quotes" }
+func (s *quotesSource) Options() sourceOptions { return s.opts }
+func (s *quotesSource) Meta() sourceMeta       { return sourceMeta{Source: s.opts.Arg} }
+
+func (s *quotesSource) Next(ctx context.Context, scr tcell.Screen, seed int64) ([]segment, error) {
+	quote := s.quotes[rand.New(rand.NewSource(seed)).Intn(len(s.quotes))]
+	return []segment{{
+		Text:        quote.Text,
+		Attribution: quote.Source,
+		Group:       s.pack.quoteGroup(quote.Length),
+	}}, nil
+}
 
-	if testtype == "words" {
-		prompt = "Generate random " + fmt.Sprint(numwords) + " words separated by spaces. Only include words that are in the English language. The words should be common and easy to type. The words should be in lowercase. No additional text."
-	} else if testtype == "quote" {
-		prompt = "Generate a famous quote in English. No additional text but the quote itself. Don't include the quotation marks."
+// printQuoteSearch lists the quotes of filename whose text or source
+// contains query, ignoring case. Starred quotes are marked with a *.
+func printQuoteSearch(filename string, query string, starred []int) {
+	quoteTestFile, err := readQuoteFile(filename)
+	if err != nil {
+		exit("%s does not appear to be a valid quote file, use -list quotes to see a list of supported quote lists\n", filename)
 	}
 
-	// prompt = "Output " + fmt.Sprint(numwords) + " common, easy-to-type English words in lowercase, separated by spaces, with no additional text."
+	query = strings.ToLower(query)
+	found := 0
+	for _, quote := range quoteTestFile.Quotes {
+		if !strings.Contains(strings.ToLower(quote.Text), query) && !strings.Contains(strings.ToLower(quote.Source), query) {
+			continue
+		}
+
+		if found == 0 {
+			fmt.Printf("  %5s  %-6s  %-60s  %s\n", "ID", "Length", "Text", "Source")
+		}
+		found++
 
-	// Send a curl request to the ollama server
-	// curl http://localhost:11434/api/generate -d '{
-	//   "model": "llama2",
-	//   "prompt": "Why is the sky blue?",
-	//   "stream": false
-	// }'
+		star := " "
+		if slices.Contains(starred, quote.ID) {
+			star = "*"
+		}
 
-	// Send curl request
-	cmd := exec.Command("curl", "http://localhost:11434/api/generate", "-d", fmt.Sprintf(`{"model": "%s", "prompt": "%s", "stream": false}`, llm, prompt))
+		text := []rune(quote.Text)
+		if len(text) > 60 {
+			text = append(text[:59], '…')
+		}
+		fmt.Printf("%s %5d  %-6s  %-60s  %s\n", star, quote.ID, quoteTestFile.quoteGroup(quote.Length), string(text), quote.Source)
+	}
 
-	// Get the output
-	output, err := cmd.Output()
+	if found == 0 {
+		fmt.Printf("No quotes in %s match %q\n", filename, query)
+	}
+}
+
+// readCustomText reads the text of a file, or of stdin for -. The screen
+// takes its keys from the terminal itself, so stdin is free to be piped in.
+func readCustomText(filename string) (string, error) {
+	var res []byte
+	var err error
+	if filename == "-" {
+		res, err = io.ReadAll(os.Stdin)
+	} else {
+		res, err = os.ReadFile(filename)
+	}
 	if err != nil {
-		exit("Error getting words from LLM: %s", err)
-	}
-
-	// Output located in a string of {"response": "words"}
-	// Parse the output
-	type LLMResponse struct {
-		Model                string  `json:"model"`
-		Response             string  `json:"response"`
-		Created_at           string  `json:"created_at"`
-		Done                 bool    `json:"done"`
-		Context              []int64 `json:"context"`
-		Total_duration       int64   `json:"total_duration"`
-		Load_duration        int64   `json:"load_duration"`
-		Prompt_eval_count    int64   `json:"prompt_eval_count"`
-		Prompt_eval_duration int64   `json:"prompt_eval_duration"`
-		Eval_count           int64   `json:"eval_count"`
-		Eval_duration        int64   `json:"eval_duration"`
-	}
-
-	var llmResponse LLMResponse
-	err = json.Unmarshal(output, &llmResponse)
+		return "", err
+	}
+
+	if strings.TrimSpace(string(res)) == "" {
+		return "", fmt.Errorf("%s is empty", filename)
+	}
+	return string(res), nil
+}
+
+// fileName is how a file of custom text or code is attributed
+func fileName(filename string) string {
+	if filename == "-" {
+		return "stdin"
+	}
+	return filepath.Base(filename)
+}
+
+// screenSegments splits lines into segments which each fit on scr without
+// scrolling.
+func screenSegments(scr tcell.Screen, lines []string, name string) []segment {
+	_, sh := scr.Size()
+	maxLines := max(sh-6, 1)
+
+	var segments []segment
+	for i := 0; i < len(lines); i += maxLines {
+		end := min(i+maxLines, len(lines))
+		segments = append(segments, segment{Text: strings.Join(lines[i:end], "\n"), Attribution: name})
+	}
+	return segments
+}
+
+// textSource types the text of a file, split into segments which each fit on
+// the screen.
+type textSource struct {
+	opts sourceOptions
+	text string
+}
+
+func newTextSource(o sourceOptions) (Source, error) {
+	if o.Arg == "" {
+		return nil, fmt.Errorf("custom needs a file, as in custom:notes.txt")
+	}
+
+	text, err := readCustomText(o.Arg)
+	if err != nil {
+		return nil, fmt.Errorf("error reading text: %s", err)
+	}
+	return &textSource{opts: sourceOptions{Arg: o.Arg}, text: text}, nil
+}
+
+func (s *textSource) Name() string           { return "custom" }
+func (s *textSource) Options() sourceOptions { return s.opts }
+func (s *textSource) Meta() sourceMeta       { return sourceMeta{Source: s.opts.Arg} }
+
+func (s *textSource) Next(ctx context.Context, scr tcell.Screen, seed int64) ([]segment, error) {
+	return screenSegments(scr, strings.Split(wrapText(scr, s.text, 80), "\n"), fileName(s.opts.Arg)), nil
+}
+
+// expandTabs replaces the tabs in line with spaces up to the next tab stop
+func expandTabs(line string, tabWidth int) string {
+	var b strings.Builder
+	col := 0
+	for _, c := range line {
+		if c == '\t' {
+			n := tabWidth - col%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+
+		b.WriteRune(c)
+		col++
+	}
+	return b.String()
+}
+
+// codeSource types source code in segments of whole lines which each fit on
+// the screen. Unlike other text its lines and indentation are kept, tabs
+// become spaces and trailing whitespace and runs of blank lines are dropped.
+type codeSource struct {
+	opts  sourceOptions
+	lines []string
+}
+
+func newCodeSource(o sourceOptions) (Source, error) {
+	if o.Arg == "" {
+		return nil, fmt.Errorf("code needs a file, as in code:main.go")
+	}
+	if o.TabWidth < 1 {
+		return nil, fmt.Errorf("-tabwidth must be at least 1")
+	}
+
+	code, err := readCustomText(o.Arg)
 	if err != nil {
-		exit("Error parsing LLM response: %s", err)
+		return nil, fmt.Errorf("error reading code: %s", err)
 	}
 
-	final_output := llmResponse.Response
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n") {
+		line = strings.TrimRight(expandTabs(line, o.TabWidth), " \t\r\f\v")
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return &codeSource{opts: sourceOptions{Arg: o.Arg, TabWidth: o.TabWidth}, lines: lines}, nil
+}
 
-	// Return a function that gets the words
-	return func() []segment {
-		return []segment{{Text: final_output, Attribution: llm}}
+func (s *codeSource) Name() string           { return "code" }
+func (s *codeSource) Options() sourceOptions { return s.opts }
+func (s *codeSource) Meta() sourceMeta       { return sourceMeta{Source: s.opts.Arg, Code: true} }
+
+func (s *codeSource) Next(ctx context.Context, scr tcell.Screen, seed int64) ([]segment, error) {
+	return screenSegments(scr, s.lines, fileName(s.opts.Arg)), nil
+}
+
+// listPacks returns the json files in dir, named without the extension
+func listPacks(dir string) []dataFile {
+	var packs []dataFile
+	for _, file := range listDataDir(dir) {
+		if filepath.Ext(file.Name) == ".json" {
+			file.Name = strings.TrimSuffix(file.Name, ".json")
+			packs = append(packs, file)
+		}
 	}
+	return packs
+}
+
+// printPacks lists the word lists or quote packs grouped by language, with
+// the layer each is read from
+func printPacks(kind string) {
+	dir := wordsDir
+	if kind == "quotes" {
+		dir = quotesDir
+	}
+
+	byLanguage := map[string][]dataFile{}
+	for _, pack := range listPacks(dir) {
+		language := wordFileLanguage(pack.Name)
+		if kind == "quotes" {
+			if q, err := readQuoteFile(pack.Name); err == nil && q.Language != "" {
+				language = q.Language
+			}
+		}
+		byLanguage[language] = append(byLanguage[language], pack)
+	}
+
+	languages := make([]string, 0, len(byLanguage))
+	for language := range byLanguage {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	for _, language := range languages {
+		fmt.Println(language)
+		for _, pack := range byLanguage[language] {
+			fmt.Printf("  %-16s %s\n", pack.Name, pack.Layer)
+		}
+	}
+}
+
+// languageName capitalises a language for use in a sentence, german is German
+func languageName(language string) string {
+	if language == "" {
+		return language
+	}
+	return strings.ToUpper(language[:1]) + language[1:]
+}
+
+// llmPrompt is the prompt asking for a words or quote test in language
+func llmPrompt(testtype string, numwords int, language string) string {
+	if testtype == "quote" {
+		return "Generate a famous quote in " + languageName(language) + ". No additional text but the quote itself. Don't include the quotation marks."
+	}
+
+	return "Generate random " + fmt.Sprint(numwords) + " words separated by spaces. Only include words that are in the " + languageName(language) + " language. The words should be common and easy to type. The words should be in lowercase. No additional text."
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWordSampler(t *testing.T) {
+	words := []string{"the", "of", "and", "to", "a", "in", "is", "you", "that", "it"}
+
+	tests := []struct {
+		name     string
+		exponent float64
+		more     bool // whether the first word should come up more than the last
+	}{
+		{"uniform", 0, false},
+		{"zipf", 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newWordSampler(words, tt.exponent, rand.New(rand.NewSource(1)))
+			counts := map[string]int{}
+			prev := ""
+
+			for i := 0; i < 10000; i++ {
+				w := s.next()
+				if w == prev {
+					t.Fatalf("%q picked twice in a row", w)
+				}
+				prev = w
+				counts[w]++
+			}
+
+			if got := counts["the"] > 3*counts["it"]; got != tt.more {
+				t.Errorf("the picked %d times, it %d times", counts["the"], counts["it"])
+			}
+		})
+	}
+}
+
+func TestRandomTextSeed(t *testing.T) {
+	words := []string{"the", "of", "and", "to", "a", "in", "is", "you", "that", "it"}
+	mods := textModifiers{Punctuation: true, Numbers: true}
+
+	text := func(seed int64) string {
+		return randomText(newWordSampler(words, 1, rand.New(rand.NewSource(seed))), 50, mods)
+	}
+
+	if a, b := text(42), text(42); a != b {
+		t.Errorf("seed 42 gave %q, then %q", a, b)
+	}
+	if a, b := text(42), text(43); a == b {
+		t.Errorf("seeds 42 and 43 both gave %q", a)
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(file, []byte("some notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		spec    string
+		name    string // name of the source, "" for an error
+		source  string // Meta().Source, the file when empty
+		wantErr string
+	}{
+		{"custom:" + file, "custom", "", ""},
+		{"code:" + file, "code", "", ""},
+		{"quotes", "quotes", "english", ""},
+		{"quotes:german", "quotes", "german", ""},
+		{"custom", "", "", "needs a file"},
+		{"custom:" + file + ".missing", "", "", "error reading text"},
+		{"nope", "", "", "there is no nope source"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			src, err := newSource(tt.spec, sourceOptions{Language: "english", NumWords: 50, Mods: textModifiers{Punctuation: true}, TabWidth: 4})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			source := tt.source
+			if source == "" {
+				source = file
+			}
+			if src.Name() != tt.name || src.Meta().Source != source {
+				t.Errorf("name, source = %q, %q, want %q, %q", src.Name(), src.Meta().Source, tt.name, source)
+			}
+			if o := src.Options(); o.NumWords != 0 || o.Mods.Punctuation {
+				t.Errorf("options %+v keep options the source doesn't use", o)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Theme files, parsed and checked before the screen is set up
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell"
+)
+
+// theme holds the colours of a theme file
+type theme struct {
+	bgcol  tcell.Color // 背景色, background
+	fgcol  tcell.Color // 前景色, untyped text
+	hicol  tcell.Color // correctly typed text
+	hicol2 tcell.Color // the current word
+	hicol3 tcell.Color // the next word
+	errcol tcell.Color // 错误色, mistakes
+}
+
+// themeKeys are the keys a theme file has to set, in the order they are
+// reported missing
+var themeKeys = []struct {
+	key  string
+	name string
+	col  func(t *theme) *tcell.Color
+}{
+	{"bgcol", "background colour", func(t *theme) *tcell.Color { return &t.bgcol }},
+	{"fgcol", "foreground colour", func(t *theme) *tcell.Color { return &t.fgcol }},
+	{"hicol", "highlight colour", func(t *theme) *tcell.Color { return &t.hicol }},
+	{"hicol2", "highlight colour 2", func(t *theme) *tcell.Color { return &t.hicol2 }},
+	{"hicol3", "highlight colour 3", func(t *theme) *tcell.Color { return &t.hicol3 }},
+	{"errcol", "error colour", func(t *theme) *tcell.Color { return &t.errcol }},
+}
+
+// themeError is a mistake in a theme file, at a line and column counted from
+// 1, or 0 for the file as a whole
+type themeError struct {
+	file      string
+	line, col int
+	msg       string
+}
+
+func (e *themeError) Error() string {
+	if e.line == 0 {
+		return fmt.Sprintf("%s: %s", e.file, e.msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.file, e.line, e.col, e.msg)
+}
+
+// parseColour reads a colour as #rgb, #rrggbb, a W3C colour name such as
+// navy, a terminal palette index from 0 to 255, or default for the colour of
+// the terminal.
+func parseColour(s string) (tcell.Color, error) {
+	if strings.HasPrefix(s, "#") {
+		hex := s[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if len(hex) != 6 || err != nil {
+			return 0, fmt.Errorf("%q is not a hex colour, use #rgb or #rrggbb", s)
+		}
+		return tcell.NewHexColor(int32(v)), nil
+	}
+
+	if i, err := strconv.Atoi(s); err == nil {
+		if i < 0 || i > 255 {
+			return 0, fmt.Errorf("palette index %d is not between 0 and 255", i)
+		}
+		return tcell.Color(i), nil
+	}
+
+	name := strings.ToLower(s)
+	if name == "default" {
+		return tcell.ColorDefault, nil
+	}
+	if c, ok := tcell.ColorNames[name]; ok {
+		return c, nil
+	}
+	return 0, fmt.Errorf("unknown colour %q", s)
+}
+
+// parseTheme reads a theme file, one "key: colour" per line. Blank lines are
+// skipped and # starts a comment, at the start of a line or after a colour.
+// Every key has to be set, once.
+//
+//	# dark, with yellow highlights
+//	bgcol: #282828
+//	fgcol: gray
+//	hicol: #fff
+//	hicol2: 136   # palette colour
+//	...
+func parseTheme(file string, src []byte) (theme, error) {
+	var t theme
+	set := map[string]int{} // line each key was set on
+
+	for i, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimRight(line, "\r")
+		errorAt := func(col int, format string, args ...interface{}) error {
+			return &themeError{file: file, line: i + 1, col: col, msg: fmt.Sprintf(format, args...)}
+		}
+
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		keyCol := len(line) - len(trimmed) + 1
+
+		colon := strings.IndexByte(line, ':')
+		if colon == -1 {
+			return t, errorAt(keyCol, "expected key: colour")
+		}
+		key := strings.TrimSpace(line[:colon])
+
+		idx := -1
+		for k, themeKey := range themeKeys {
+			if themeKey.key == key {
+				idx = k
+			}
+		}
+		if idx == -1 {
+			var keys []string
+			for _, themeKey := range themeKeys {
+				keys = append(keys, themeKey.key)
+			}
+			return t, errorAt(keyCol, "unknown key %q, expected one of %s", key, strings.Join(keys, ", "))
+		}
+		if prev, ok := set[key]; ok {
+			return t, errorAt(keyCol, "%s is already set on line %d", key, prev)
+		}
+
+		// The colour, then nothing but a comment
+		value := strings.TrimLeft(line[colon+1:], " \t")
+		valueCol := len(line) - len(value) + 1
+		end := strings.IndexAny(value, " \t")
+		if end == -1 {
+			end = len(value)
+		}
+		if end == 0 {
+			return t, errorAt(valueCol, "no colour for %s", key)
+		}
+		if after := strings.TrimLeft(value[end:], " \t"); after != "" && !strings.HasPrefix(after, "#") {
+			return t, errorAt(len(line)-len(after)+1, "unexpected %q after the colour, comments start with #", after)
+		}
+
+		c, err := parseColour(value[:end])
+		if err != nil {
+			return t, errorAt(valueCol, "%s", err)
+		}
+		*themeKeys[idx].col(&t) = c
+		set[key] = i + 1
+	}
+
+	for _, themeKey := range themeKeys {
+		if _, ok := set[themeKey.key]; !ok {
+			return t, &themeError{file: file, msg: fmt.Sprintf("%s (%s) is not set", themeKey.key, themeKey.name)}
+		}
+	}
+	return t, nil
+}
+
+// readTheme reads and checks the theme of the given name from the themes
+// directories. Mistakes are reported at the path of the file it was read from.
+func readTheme(name string) (theme, error) {
+	src, file, err := readDataFile(fmt.Sprintf("themes/%s.txt", name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return theme{}, fmt.Errorf("%s does not appear to be a valid theme, try running `gotype -list themes` to list available themes", name)
+	} else if err != nil {
+		return theme{}, err
+	}
+
+	return parseTheme(file, src)
+}
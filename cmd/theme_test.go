@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestParseColour(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    tcell.Color
+		wantErr bool
+	}{
+		{"#282828", tcell.NewRGBColor(0x28, 0x28, 0x28), false},
+		{"#fA0", tcell.NewRGBColor(0xff, 0xaa, 0x00), false},
+		{"navy", tcell.ColorNavy, false},
+		{"Red", tcell.ColorRed, false},
+		{"136", tcell.Color(136), false},
+		{"default", tcell.ColorDefault, false},
+		{"#zzzzzz", 0, true},
+		{"#12345", 0, true},
+		{"256", 0, true},
+		{"blurple", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseColour(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseColour(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTheme(t *testing.T) {
+	valid := "# a theme\nbgcol: #282828\nfgcol: gray\n\nhicol: #fff  # white\nhicol2: 136\nhicol3: #e8a522\r\nerrcol:maroon\n"
+
+	tests := []struct {
+		name string
+		src  string
+		want string // the error, "" for none
+	}{
+		{"valid", valid, ""},
+		{"bad hex", strings.Replace(valid, "#282828", "#zzzzzz", 1), "t.txt:2:8: \"#zzzzzz\" is not a hex colour"},
+		{"unknown key", valid + "  bgcolour: #000\n", "t.txt:9:3: unknown key \"bgcolour\""},
+		{"set twice", valid + "fgcol: #000\n", "t.txt:9:1: fgcol is already set on line 3"},
+		{"no colon", valid + "fgcol #000\n", "t.txt:9:1: expected key: colour"},
+		{"no colour", strings.Replace(valid, "errcol:maroon", "errcol:  ", 1), "t.txt:8:10: no colour for errcol"},
+		{"trailing text", strings.Replace(valid, "136", "136 dark", 1), "t.txt:6:13: unexpected \"dark\""},
+		{"missing key", strings.Replace(valid, "hicol3", "# hicol3", 1), "t.txt: hicol3 (highlight colour 3) is not set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th, err := parseTheme("t.txt", []byte(tt.src))
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if th.fgcol != tcell.ColorGray || th.hicol != tcell.NewRGBColor(0xff, 0xff, 0xff) || th.errcol != tcell.ColorMaroon {
+					t.Errorf("theme = %+v", th)
+				}
+				return
+			}
+
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadTheme(t *testing.T) {
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	if _, err := readTheme("default"); err != nil {
+		t.Errorf("default theme: %s", err)
+	}
+	if _, err := readTheme("nope"); err == nil || !strings.Contains(err.Error(), "-list themes") {
+		t.Errorf("err = %v, want it to point to -list themes", err)
+	}
+
+	// Mistakes are reported in the file the theme was read from
+	file := filepath.Join(config, "gotype", "themes", "broken.txt")
+	os.MkdirAll(filepath.Dir(file), 0755)
+	if err := os.WriteFile(file, []byte("bgcol: nope\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readTheme("broken"); err == nil || !strings.HasPrefix(err.Error(), file+":1:8: ") {
+		t.Errorf("err = %v, want it at %s:1:8", err, file)
+	}
+}
@@ -3,17 +3,20 @@ package main
 import (
 	"fmt" // fmt包提供了I/O函数
 	"io"  // io包提供了基本的接口
+	"math"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell"
 )
 
 type segment struct {
-	Text        string // 文本
-	Attribution string // 归因
+	Text        string `json:"text"`            // 文本
+	Attribution string `json:"attribution"`     // 归因
+	Group       string `json:"group,omitempty"` // 长度组, length group of a quote
 }
 
 type mistake struct {
@@ -21,15 +24,147 @@ type mistake struct {
 	Typed string `json:"typed"`
 }
 
+// wpmSample is a snapshot of the typing speed taken every second of a test
+type wpmSample struct {
+	Wpm    int `json:"wpm"`    // 净速度, net wpm over the whole test so far
+	Raw    int `json:"raw"`    // 原始速度, wpm over this second counting every keystroke
+	Errors int `json:"errors"` // 错误数, mistyped characters during this second
+}
+
+// sampler collects one wpmSample per second, carried across the segments of a test
+type sampler struct {
+	samples   []wpmSample
+	elapsed   time.Duration // 之前段落的时间, time spent in finished segments
+	wordChars int           // 之前段落的正确数, correct word characters in finished segments
+	nkeys     int           // keystrokes since the last sample
+	nerrs     int           // errors since the last sample
+}
+
+// sample appends a sample for every whole second in elapsed that hasn't been
+// sampled yet. wordChars is the number of correct word characters in the
+// current segment, see countWordChars.
+func (s *sampler) sample(elapsed time.Duration, wordChars int) {
+	elapsed += s.elapsed
+
+	for n := len(s.samples) + 1; time.Duration(n)*time.Second <= elapsed; n++ {
+		s.samples = append(s.samples, wpmSample{
+			Wpm:    (s.wordChars + wordChars) * 60 / 5 / n,
+			Raw:    s.nkeys * 60 / 5,
+			Errors: s.nerrs,
+		})
+
+		s.nkeys = 0
+		s.nerrs = 0
+	}
+}
+
+// finish adds a sample for the trailing partial second, if anything was
+// typed during it, and returns every sample taken.
+func (s *sampler) finish() []wpmSample {
+	rem := s.elapsed - time.Duration(len(s.samples))*time.Second
+	if s.nkeys > 0 && rem > 0 {
+		s.samples = append(s.samples, wpmSample{
+			Wpm:    int(float64(s.wordChars) / 5 / (float64(s.elapsed) / 60e9)),
+			Raw:    int(float64(s.nkeys) / 5 / (float64(rem) / 60e9)),
+			Errors: s.nerrs,
+		})
+
+		s.nkeys = 0
+		s.nerrs = 0
+	}
+
+	return s.samples
+}
+
+// testStats holds the keystroke level statistics of a test, which are used to
+// calculate the same metrics MonkeyType reports.
+type testStats struct {
+	Keystrokes  int // 按键数, every character typed, including ones deleted later
+	KeyErrors   int // 错误按键数, keystrokes which didn't match the text
+	Corrected   int // 已更正错误, mistyped characters which were deleted again
+	Uncorrected int // 未更正错误, mistyped characters left in the text at the end
+	WordChars   int // characters of correctly typed words and correctly typed spaces
+	RawChars    int // every character left in the text at the end, correct or not
+	Samples     []wpmSample
+
+	smp sampler // collects Samples while the test runs
+}
+
+// Wpm returns the net words per minute, counting only correctly typed words
+func (s testStats) Wpm(d time.Duration) int {
+	return int(float64(s.WordChars) / 5 / (float64(d) / 60e9))
+}
+
+// RawWpm returns the words per minute counting every character typed
+func (s testStats) RawWpm(d time.Duration) int {
+	return int(float64(s.RawChars) / 5 / (float64(d) / 60e9))
+}
+
+// Accuracy returns the percentage of keystrokes which were correct
+func (s testStats) Accuracy() float64 {
+	if s.Keystrokes == 0 {
+		return 0
+	}
+
+	return float64(s.Keystrokes-s.KeyErrors) / float64(s.Keystrokes) * 100
+}
+
+// Consistency returns how steady the raw speed was across the test, as a
+// percentage mapped from the coefficient of variation of the per second raw wpm.
+func (s testStats) Consistency() float64 {
+	if len(s.Samples) == 0 {
+		return 0
+	}
+
+	var mean, variance float64
+	for _, smp := range s.Samples {
+		mean += float64(smp.Raw)
+	}
+	mean /= float64(len(s.Samples))
+
+	if mean == 0 {
+		return 0
+	}
+
+	for _, smp := range s.Samples {
+		variance += (float64(smp.Raw) - mean) * (float64(smp.Raw) - mean)
+	}
+	variance /= float64(len(s.Samples))
+
+	return kogasa(math.Sqrt(variance) / mean)
+}
+
+// kogasa maps a coefficient of variation onto 0-100, as MonkeyType does
+func kogasa(cov float64) float64 {
+	return 100 * (1 - math.Tanh(cov+math.Pow(cov, 3)/3+math.Pow(cov, 5)/5))
+}
+
+// EventSource supplies the events which drive a test. tcell.Screen satisfies
+// it, other sources can be used to replay or script the input.
+type EventSource interface {
+	// PollEvent blocks until the next event, a nil event redraws the screen
+	PollEvent() tcell.Event
+	// PostEventWait queues ev, it is used to wake up PollEvent periodically
+	PostEventWait(ev tcell.Event)
+}
+
 // Represents our gotype object
 type gotype struct {
-	scr              tcell.Screen // gotype的窗口
-	tty              io.Writer    // tty是一个io.Writer接口
-	OnStart          func()       // 开始时的回调函数
-	SkipWord         bool         // 是否跳过单词
-	ShowWpm          bool         // 是否显示每分钟字数
-	DisableBackspace bool         // 是否禁用退格键
-	BlockCursor      bool         // 是否显示块光标
+	scr              tcell.Screen  // gotype的窗口
+	tty              io.Writer     // tty是一个io.Writer接口
+	OnStart          func()        // 开始时的回调函数
+	SkipWord         bool          // 是否跳过单词
+	ShowWpm          bool          // 是否显示每分钟字数
+	DisableBackspace bool          // 是否禁用退格键
+	BlockCursor      bool          // 是否显示块光标
+	Recorder         *recorder     // 按键录制, records key events when not nil
+	Stream           func() string // 流式文本, more wrapped text to append as the end comes near, "" when exhausted
+	Code             bool          // 代码模式, every newline is typed with enter, nothing is skipped but indentation
+	TypeIndent       bool          // 输入缩进, in code mode the leading indentation is typed rather than skipped
+	TabWidth         int           // 制表符宽度, spaces typed by the tab key in code mode
+
+	Events EventSource      // 事件来源, source of key events, the screen by default
+	Now    func() time.Time // 时钟, clock used to time the test, time.Now by default
 
 	defaultStyle        tcell.Style // 默认样式
 	currentWordStyle    tcell.Style // 当前单词的样式
@@ -41,6 +176,9 @@ type gotype struct {
 	correctStyle        tcell.Style // 正确的样式
 }
 
+// streamLines is the number of lines shown while text is streamed in
+const streamLines = 3
+
 // GoType States
 const (
 	GoTypeComplete = iota
@@ -56,45 +194,8 @@ func exit(format string, args ...interface{}) {
 	os.Exit(1)
 }
 
-func createGoType(scr tcell.Screen, bold bool, themeName string) *gotype {
-	var theme map[string]string
-
-	// Read the theme file
-	if result := readTheme(themeName); result == nil {
-		exit("%s does not appear to be a valid theme, try running `gotype -list themes` to list available themes", themeName)
-	} else {
-		theme = result
-	}
-
-	// bgcol background color
-	// fgcol foreground color
-	// hicol highlight color
-	// hicol2 highlight color 2
-	// hicol3 highlight color 3
-	var bgcol, fgcol, hicol, hicol2, hicol3, errcol tcell.Color
-	var err error
-
-	// Check if the colors are valid hex colors
-	if bgcol, err = newTcellColor(theme["bgcol"]); err != nil {
-		exit("Background color is not defined and/or a valid hex color")
-	}
-	if fgcol, err = newTcellColor(theme["fgcol"]); err != nil {
-		exit("Foreground color is not defined and/or a valid hex color")
-	}
-	if hicol, err = newTcellColor(theme["hicol"]); err != nil {
-		exit("Highlight color is not defined and/or a valid hex color")
-	}
-	if hicol2, err = newTcellColor(theme["hicol2"]); err != nil {
-		exit("Highlight color 2 is not defined and/or a valid hex color")
-	}
-	if hicol3, err = newTcellColor(theme["hicol3"]); err != nil {
-		exit("Highlight color 3 is not defined and/or a valid hex color")
-	}
-	if errcol, err = newTcellColor(theme["errcol"]); err != nil {
-		exit("Error color is not defined and/or a valid hex color")
-	}
-
-	return NewGoType(scr, bold, bgcol, fgcol, hicol, hicol2, hicol3, errcol)
+func createGoType(scr tcell.Screen, bold bool, theme theme) *gotype {
+	return NewGoType(scr, bold, theme.bgcol, theme.fgcol, theme.hicol, theme.hicol2, theme.hicol3, theme.errcol)
 }
 
 // NewGoType creates a new gotype object
@@ -121,6 +222,8 @@ func NewGoType(scr tcell.Screen, emboldenTypedText bool, bgcol, fgcol, hicol, hi
 		scr:      scr,
 		SkipWord: true,
 		tty:      tty,
+		Events:   scr,
+		Now:      time.Now,
 
 		defaultStyle:        scrSetupRes,
 		correctStyle:        correctStyle,
@@ -131,8 +234,13 @@ func NewGoType(scr tcell.Screen, emboldenTypedText bool, bgcol, fgcol, hicol, hi
 	}
 }
 
-func (t *gotype) StartTest(text []segment, timeout time.Duration) (numerrs, numcorrect int, duration time.Duration, rc int, mistakes []mistake, wpms []int) {
+func (t *gotype) StartTest(text []segment, timeout time.Duration) (numerrs, numcorrect int, duration time.Duration, rc int, mistakes []mistake, stats testStats) {
 	timeLeft := timeout
+	t.Recorder.reset(text, t.Now)
+
+	defer func() {
+		stats.Samples = stats.smp.finish()
+	}()
 
 	for idx, seg := range text {
 		startImmediately := true
@@ -144,7 +252,11 @@ func (t *gotype) StartTest(text []segment, timeout time.Duration) (numerrs, numc
 			startImmediately = false
 		}
 
-		e, c, rc, d, m = t.play(seg.Text, timeLeft, startImmediately, seg.Attribution)
+		if t.Recorder != nil {
+			t.Recorder.segment = idx
+		}
+
+		e, c, rc, d, m = t.play(seg.Text, timeLeft, startImmediately, seg.Attribution, &stats)
 
 		numerrs += e                      // 错误数
 		numcorrect += c                   // 正确数
@@ -166,16 +278,102 @@ func (t *gotype) StartTest(text []segment, timeout time.Duration) (numerrs, numc
 	return
 }
 
+// startTicker wakes the event loop up every half second, so the timer is
+// redrawn and the time limit checked while nothing is typed. It runs until
+// the returned function is called.
+func (t *gotype) startTicker() (stop func()) {
+	tickerCloser := make(chan bool)
+
+	//Inject nil events into the main event loop at regular intervals to force an update
+	go func() {
+		for {
+			select {
+			case <-tickerCloser:
+				return
+			default:
+			}
+
+			time.Sleep(time.Duration(5e8))
+			t.Events.PostEventWait(nil)
+		}
+	}()
+
+	return func() { close(tickerCloser) }
+}
+
+// isDeleteWord reports whether ev deletes the previous word: ctrl+w, or
+// backspace with alt or ctrl held
+func isDeleteWord(ev *tcell.EventKey) bool {
+	switch ev.Key() {
+	case tcell.KeyCtrlW:
+		return true
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if runtime.GOOS != "windows" && ev.Key() == tcell.KeyBackspace { //Control+backspace on unix terms
+			return true
+		}
+		return ev.Modifiers() == tcell.ModAlt || ev.Modifiers() == tcell.ModCtrl
+	}
+	return false
+}
+
 // play函数进行打字环节, core game logic
-func (t *gotype) play(s string, timeLimit time.Duration, startImmediately bool, attribution string) (nerrs int, ncorrect int, rc int, duration time.Duration, mistakes []mistake) {
+func (t *gotype) play(s string, timeLimit time.Duration, startImmediately bool, attribution string, st *testStats) (nerrs int, ncorrect int, rc int, duration time.Duration, mistakes []mistake) {
 	var startTime time.Time
 	text := []rune(s)
 	typed := make([]rune, len(text))
 
-	sw, sh := scr.Size()
-	nc, nr := calcStringDimensions(s) // 计算字符串的维度
-	x := (sw - nc) / 2
-	y := (sh - nr) / 2
+	stream := t.Stream
+
+	// extend appends streamed text until there are at least streamLines lines
+	// from the cursor onwards
+	extend := func(idx int) {
+		for stream != nil {
+			ahead := 0
+			for i := idx; i < len(text); i++ {
+				if text[i] == '\n' {
+					ahead++
+				}
+			}
+
+			if ahead >= streamLines-1 {
+				return
+			}
+
+			more := stream()
+			if more == "" {
+				stream = nil
+				return
+			}
+
+			chunk := []rune(" \n" + more)
+			text = append(text, chunk...)
+			typed = append(typed, make([]rune, len(chunk))...)
+			t.Recorder.extend(string(chunk))
+		}
+	}
+	extend(0)
+
+	sw, sh := t.scr.Size()
+	nc, nr := calcStringDimensions(string(text)) // 计算字符串的维度
+
+	// Text which is streamed in, or too tall for the screen, scrolls with the
+	// cursor, showing vr lines at a time
+	scroll := false
+	vr := nr
+	if t.Stream != nil {
+		scroll = true
+		vr = streamLines
+	} else if maxr := sh - 6; nr > maxr && maxr > 0 {
+		scroll = true
+		vr = maxr
+	}
+
+	// Lines wider than the screen, as code can have, scroll sideways to keep
+	// the cursor in view, showing vc columns at a time
+	vc := min(nc, sw)
+
+	x := (sw - vc) / 2
+	y := (sh - vr) / 2
 
 	if !t.BlockCursor {
 		t.tty.Write([]byte("\033[5 q"))
@@ -188,6 +386,35 @@ func (t *gotype) play(s string, timeLimit time.Duration, startImmediately bool,
 	t.scr.SetStyle(t.defaultStyle)
 	idx := 0
 
+	// skipped reports whether the character at i is filled in rather than
+	// typed: the line breaks of wrapped text, and in code mode the leading
+	// indentation of a line unless TypeIndent is set
+	skipped := func(i int) bool {
+		if !t.Code {
+			return text[i] == '\n'
+		}
+
+		if t.TypeIndent || text[i] != ' ' {
+			return false
+		}
+
+		for j := i - 1; j >= 0 && text[j] != '\n'; j-- {
+			if text[j] != ' ' {
+				return false
+			}
+		}
+		return true
+	}
+
+	// skip moves the cursor past the skipped characters in front of it
+	skip := func() {
+		for idx < len(text) && skipped(idx) {
+			typed[idx] = text[idx]
+			idx++
+		}
+	}
+	skip()
+
 	calcStats := func() {
 		nerrs = 0
 		ncorrect = 0
@@ -195,7 +422,7 @@ func (t *gotype) play(s string, timeLimit time.Duration, startImmediately bool,
 		mistakes = extractMistypedWords(text[:idx], typed[:idx])
 
 		for i := 0; i < idx; i++ {
-			if text[i] != '\n' {
+			if !skipped(i) {
 				if text[i] != typed[i] {
 					nerrs++
 				} else {
@@ -206,28 +433,127 @@ func (t *gotype) play(s string, timeLimit time.Duration, startImmediately bool,
 
 		rc = GoTypeComplete
 		// duration = time.Now().Sub(startTime)
-		duration = time.Since(startTime)
+		duration = t.Now().Sub(startTime)
+	}
+
+	// Take the per second samples which are due, and fold the segment into
+	// the test stats once it is over
+	sample := func() {
+		if startTime.IsZero() {
+			return
+		}
+
+		wordChars, _ := countWordChars(text[:idx], typed[:idx], skipped)
+		st.smp.sample(t.Now().Sub(startTime), wordChars)
+	}
+
+	defer func() {
+		if rc == GoTypeComplete {
+			wordChars, rawChars := countWordChars(text[:idx], typed[:idx], skipped)
+			st.WordChars += wordChars
+			st.RawChars += rawChars
+			st.Uncorrected += nerrs
+
+			st.smp.sample(duration, wordChars)
+			st.smp.elapsed += duration
+			st.smp.wordChars += wordChars
+		}
+	}()
+
+	// keystroke counts a typed character towards the test stats
+	keystroke := func(correct bool) {
+		st.Keystrokes++
+		st.smp.nkeys++
+
+		if !correct {
+			st.KeyErrors++
+			st.smp.nerrs++
+		}
+	}
+
+	// corrected counts the mistyped characters in typed[from:to] which are
+	// about to be deleted
+	corrected := func(from, to int) {
+		for i := from; i < to; i++ {
+			if !skipped(i) && typed[i] != text[i] {
+				st.Corrected++
+			}
+		}
 	}
 
 	redraw := func() {
-		cx := x
+		// Keep the cursor a quarter of the screen from the right edge while
+		// scrolling sideways
+		left := 0
+		if nc > vc {
+			col := 0
+			for i := min(idx, len(text)) - 1; i >= 0 && text[i] != '\n'; i-- {
+				col++
+			}
+
+			left = max(col-vc*3/4, 0)
+		}
+
+		cx := x - left
 		cy := y
 		inword := -1
 
+		if scroll || nc > vc {
+			for r := 0; r < vr; r++ {
+				drawString(t.scr, x, y+r, strings.Repeat(" ", vc), -1, t.defaultStyle)
+			}
+		}
+
+		// Keep the cursor on the second visible line while scrolling
+		if scroll {
+			top := 0
+			for i := 0; i < idx && i < len(text); i++ {
+				if text[i] == '\n' {
+					top++
+				}
+			}
+
+			if top > 0 {
+				top--
+			}
+
+			cy -= top
+		}
+
+		// visible reports whether the cell at cx, cy is in the text area
+		visible := func() bool {
+			return cx >= x && cx < x+vc && cy >= y && cy < y+vr
+		}
+
 		for i := range text {
 			style := t.defaultStyle
 
 			if text[i] == '\n' {
+				// Line breaks are typed in code mode, a wrong one is marked
+				if t.Code && visible() {
+					if i == idx {
+						t.scr.ShowCursor(cx, cy)
+					} else if i < idx && typed[i] != '\n' {
+						t.scr.SetContent(cx, cy, '↵', nil, t.incorrectStyle)
+					}
+				}
+
 				cy++
-				cx = x
+				cx = x - left
 				if inword != -1 {
 					inword++
 				}
 				continue
 			}
 
+			if cy >= y+vr {
+				break
+			}
+
 			if i == idx {
-				scr.ShowCursor(cx, cy)
+				if visible() {
+					t.scr.ShowCursor(cx, cy)
+				}
 				inword = 0
 			}
 
@@ -251,25 +577,28 @@ func (t *gotype) play(s string, timeLimit time.Duration, startImmediately bool,
 				style = t.correctStyle
 			}
 
-			scr.SetContent(cx, cy, text[i], nil, style)
+			if visible() {
+				t.scr.SetContent(cx, cy, text[i], nil, style)
+			}
 			cx++
 		}
 
 		aw, ah := calcStringDimensions(attribution)
-		drawString(t.scr, x+nc-aw, y+nr+1, attribution, -1, t.defaultStyle)
+		drawString(t.scr, x+vc-aw, y+vr+1, attribution, -1, t.defaultStyle)
 
 		if timeLimit != -1 && !startTime.IsZero() {
 			// remaining := timeLimit - time.Now().Sub(startTime)
-			remaining := timeLimit - time.Since(startTime)
-			drawString(t.scr, x+nc/2, y+nr+ah+1, "      ", -1, t.defaultStyle)
-			drawString(t.scr, x+nc/2, y+nr+ah+1, strconv.Itoa(int(remaining/1e9)+1), -1, t.defaultStyle)
+			remaining := timeLimit - t.Now().Sub(startTime)
+			drawString(t.scr, x+vc/2, y+vr+ah+1, "      ", -1, t.defaultStyle)
+			drawString(t.scr, x+vc/2, y+vr+ah+1, strconv.Itoa(int(remaining/1e9)+1), -1, t.defaultStyle)
 		}
 
 		if t.ShowWpm && !startTime.IsZero() {
 			calcStats()
 			if duration > 1e7 { //Avoid flashing large numbers on test start.
-				wpm := int((float64(ncorrect) / 5) / (float64(duration) / 60e9))
-				drawString(t.scr, x+nc/2-4, y-2, fmt.Sprintf("WPM: %-10d\n", wpm), -1, t.defaultStyle)
+				wordChars, _ := countWordChars(text[:idx], typed[:idx], skipped)
+				wpm := int((float64(st.smp.wordChars+wordChars) / 5) / (float64(st.smp.elapsed+duration) / 60e9))
+				drawString(t.scr, x+vc/2-4, y-2, fmt.Sprintf("WPM: %-10d\n", wpm), -1, t.defaultStyle)
 			}
 		}
 
@@ -282,6 +611,10 @@ func (t *gotype) play(s string, timeLimit time.Duration, startImmediately bool,
 			return
 		}
 
+		t.Recorder.record(keyDeleteWord, 0, idx)
+		end := idx
+		defer func() { corrected(idx, end) }()
+
 		idx--
 
 		for idx > 0 && (text[idx] == ' ' || text[idx] == '\n') {
@@ -296,43 +629,30 @@ func (t *gotype) play(s string, timeLimit time.Duration, startImmediately bool,
 			typed[idx] = text[idx]
 			idx++
 		}
+		skip()
 	}
 
-	tickerCloser := make(chan bool)
-
-	//Inject nil events into the main event loop at regular invervals to force an update
-	ticker := func() {
-		for {
-			select {
-			case <-tickerCloser:
-				return
-			default:
-			}
-
-			time.Sleep(time.Duration(5e8))
-			t.scr.PostEventWait(nil)
-		}
-	}
-
-	go ticker()
-	defer close(tickerCloser)
+	stopTicker := t.startTicker()
+	defer stopTicker()
 
 	if startImmediately {
-		startTime = time.Now()
+		startTime = t.Now()
 	}
 
 	t.scr.Clear()
 	for {
 		redraw()
 
-		ev := t.scr.PollEvent()
+		ev := t.Events.PollEvent()
 
 		switch ev := ev.(type) {
 		case *tcell.EventResize:
 			rc = GoTypeResize
 			return
 		case *tcell.EventKey:
-			if runtime.GOOS != "windows" && ev.Key() == tcell.KeyBackspace { //Control+backspace on unix terms
+			sample()
+
+			if isDeleteWord(ev) {
 				if !t.DisableBackspace {
 					deleteWord()
 				}
@@ -340,7 +660,7 @@ func (t *gotype) play(s string, timeLimit time.Duration, startImmediately bool,
 			}
 
 			if startTime.IsZero() {
-				startTime = time.Now()
+				startTime = t.Now()
 			}
 
 			switch key := ev.Key(); key {
@@ -363,34 +683,47 @@ func (t *gotype) play(s string, timeLimit time.Duration, startImmediately bool,
 				rc = GoTypePrevious
 				return
 
-			case tcell.KeyCtrlW:
-				if !t.DisableBackspace {
-					deleteWord()
-				}
-
 			case tcell.KeyBackspace, tcell.KeyBackspace2:
-				if !t.DisableBackspace {
-					if ev.Modifiers() == tcell.ModAlt || ev.Modifiers() == tcell.ModCtrl {
-						deleteWord()
-					} else {
-						if idx == 0 {
-							break
-						}
+				if !t.DisableBackspace && idx > 0 {
+					t.Recorder.record(keyBackspace, 0, idx)
+					end := idx
+					idx--
 
+					for idx > 0 && skipped(idx) {
 						idx--
+					}
 
-						for idx > 0 && text[idx] == '\n' {
-							idx--
-						}
+					corrected(idx, end)
+					skip()
+				}
+			case tcell.KeyRune, tcell.KeyEnter, tcell.KeyTab:
+				var runes []rune
+				switch {
+				case key == tcell.KeyRune:
+					runes = []rune{ev.Rune()}
+				case key == tcell.KeyEnter && t.Code:
+					runes = []rune{'\n'}
+				case key == tcell.KeyTab && t.Code:
+					// Tab types up to TabWidth spaces of indentation
+					runes = []rune{' '}
+					for len(runes) < t.TabWidth && idx+len(runes) < len(text) && text[idx+len(runes)] == ' ' {
+						runes = append(runes, ' ')
 					}
 				}
-			case tcell.KeyRune:
-				if idx < len(text) {
-					if t.SkipWord && ev.Rune() == ' ' {
+
+				for _, r := range runes {
+					if idx >= len(text) {
+						break
+					}
+
+					if t.SkipWord && r == ' ' {
 						if idx > 0 && text[idx-1] == ' ' && text[idx] != ' ' { //Do nothing on word boundaries.
 							break
 						}
 
+						keystroke(text[idx] == ' ' || text[idx] == '\n')
+						t.Recorder.record(keySkip, ' ', idx)
+
 						for idx < len(text) && text[idx] != ' ' && text[idx] != '\n' {
 							typed[idx] = 0
 							idx++
@@ -401,25 +734,32 @@ func (t *gotype) play(s string, timeLimit time.Duration, startImmediately bool,
 							idx++
 						}
 					} else {
-						typed[idx] = ev.Rune()
-						idx++
-					}
+						keystroke(r == text[idx])
+						t.Recorder.record(keyRune, r, idx)
 
-					for idx < len(text) && text[idx] == '\n' {
-						typed[idx] = text[idx]
+						typed[idx] = r
 						idx++
 					}
+
+					skip()
 				}
 
+				extend(idx)
+
 				if idx == len(text) {
 					calcStats()
 					return
 				}
 			}
 		default: //tick
+			sample()
+
 			// if timeLimit != -1 && !startTime.IsZero() && timeLimit <= time.Now().Sub(startTime) {
-			if timeLimit != -1 && !startTime.IsZero() && timeLimit <= time.Since(startTime) {
+			if timeLimit != -1 && !startTime.IsZero() && timeLimit <= t.Now().Sub(startTime) {
 				calcStats()
+
+				// The tick may come a little late, results are based on the time limit
+				duration = timeLimit
 				return
 			}
 
@@ -0,0 +1,533 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+)
+
+// step is a scripted event, delivered dt after the previous one
+type step struct {
+	ev tcell.Event
+	dt time.Duration
+}
+
+// scriptedEvents is an EventSource which plays back a fixed list of steps on
+// a fake clock. Ticks posted by the engine are dropped so runs are
+// deterministic, and a script which runs out interrupts the test.
+type scriptedEvents struct {
+	steps []step
+	clock time.Time
+}
+
+func (s *scriptedEvents) PollEvent() tcell.Event {
+	if len(s.steps) == 0 {
+		return tcell.NewEventKey(tcell.KeyCtrlC, 0, tcell.ModCtrl)
+	}
+
+	st := s.steps[0]
+	s.steps = s.steps[1:]
+	s.clock = s.clock.Add(st.dt)
+
+	return st.ev
+}
+
+func (s *scriptedEvents) PostEventWait(ev tcell.Event) {}
+
+func (s *scriptedEvents) now() time.Time {
+	return s.clock
+}
+
+const keyInterval = 100 * time.Millisecond
+
+// typeKeys types every rune in s, one every keyInterval
+func typeKeys(s string) []step {
+	var steps []step
+	for _, r := range s {
+		steps = append(steps, step{tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone), keyInterval})
+	}
+
+	return steps
+}
+
+// press presses a single special key
+func press(k tcell.Key, mod tcell.ModMask) []step {
+	return []step{{tcell.NewEventKey(k, 0, mod), keyInterval}}
+}
+
+// wait lets d pass before the engine is woken up by a tick
+func wait(d time.Duration) []step {
+	return []step{{nil, d}}
+}
+
+func script(parts ...[]step) []step {
+	var steps []step
+	for _, p := range parts {
+		steps = append(steps, p...)
+	}
+
+	return steps
+}
+
+func newTestGoType(steps []step) *gotype {
+	sim := tcell.NewSimulationScreen("UTF-8")
+	if err := sim.Init(); err != nil {
+		panic(err)
+	}
+	sim.SetSize(80, 24)
+
+	src := &scriptedEvents{steps: steps, clock: time.Unix(0, 0)}
+
+	t := NewGoType(sim, false, tcell.ColorBlack, tcell.ColorWhite, tcell.ColorGreen, tcell.ColorYellow, tcell.ColorBlue, tcell.ColorRed)
+	t.tty = io.Discard
+	t.Events = src
+	t.Now = src.now
+
+	return t
+}
+
+func TestPlay(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		steps    []step
+		noSkip   bool
+		noBack   bool
+		unixOnly bool
+		rc       int
+		nerrs    int
+		ncorrect int
+		mistakes []mistake
+	}{
+		{
+			name:     "perfect",
+			text:     "hello world",
+			steps:    typeKeys("hello world"),
+			rc:       GoTypeComplete,
+			ncorrect: 11,
+		},
+		{
+			name:     "typo",
+			text:     "hello world",
+			steps:    typeKeys("hellp world"),
+			rc:       GoTypeComplete,
+			nerrs:    1,
+			ncorrect: 10,
+			mistakes: []mistake{{"hello", "hellp"}},
+		},
+		{
+			name:     "skip word",
+			text:     "hello world",
+			steps:    typeKeys("he world"),
+			rc:       GoTypeComplete,
+			nerrs:    3,
+			ncorrect: 8,
+			mistakes: []mistake{{"hello", "he___"}},
+		},
+		{
+			name:     "no skip",
+			text:     "hello world",
+			steps:    typeKeys("he world   "),
+			noSkip:   true,
+			rc:       GoTypeComplete,
+			nerrs:    8,
+			ncorrect: 3,
+			mistakes: []mistake{{"hello", "he wo"}, {"world", "ld   "}},
+		},
+		{
+			name:     "space on word boundary is ignored",
+			text:     "hello world",
+			steps:    typeKeys("hello  world"),
+			rc:       GoTypeComplete,
+			ncorrect: 11,
+		},
+		{
+			name:     "backspace",
+			text:     "hello world",
+			steps:    script(typeKeys("hellp"), press(tcell.KeyBackspace2, tcell.ModNone), typeKeys("o world")),
+			rc:       GoTypeComplete,
+			ncorrect: 11,
+		},
+		{
+			name:     "backspace at start",
+			text:     "hello",
+			steps:    script(press(tcell.KeyBackspace2, tcell.ModNone), typeKeys("hello")),
+			rc:       GoTypeComplete,
+			ncorrect: 5,
+		},
+		{
+			name:     "backspace disabled",
+			text:     "hello world",
+			steps:    script(typeKeys("hellp"), press(tcell.KeyBackspace2, tcell.ModNone), typeKeys(" world")),
+			noBack:   true,
+			rc:       GoTypeComplete,
+			nerrs:    1,
+			ncorrect: 10,
+			mistakes: []mistake{{"hello", "hellp"}},
+		},
+		{
+			name:     "ctrl+w inside word",
+			text:     "hello world",
+			steps:    script(typeKeys("hello wpr"), press(tcell.KeyCtrlW, tcell.ModCtrl), typeKeys("world")),
+			rc:       GoTypeComplete,
+			ncorrect: 11,
+		},
+		{
+			name:     "ctrl+w after space",
+			text:     "hello world",
+			steps:    script(typeKeys("hellp "), press(tcell.KeyCtrlW, tcell.ModCtrl), typeKeys("hello world")),
+			rc:       GoTypeComplete,
+			ncorrect: 11,
+		},
+		{
+			name:     "ctrl+w inside first word",
+			text:     "hello world",
+			steps:    script(typeKeys("hel"), press(tcell.KeyCtrlW, tcell.ModCtrl), typeKeys("hello world")),
+			rc:       GoTypeComplete,
+			ncorrect: 11,
+		},
+		{
+			name:     "ctrl+w at start",
+			text:     "hello",
+			steps:    script(press(tcell.KeyCtrlW, tcell.ModCtrl), typeKeys("hello")),
+			rc:       GoTypeComplete,
+			ncorrect: 5,
+		},
+		{
+			name:     "ctrl+w disabled",
+			text:     "hello world",
+			steps:    script(typeKeys("hellp"), press(tcell.KeyCtrlW, tcell.ModCtrl), typeKeys(" world")),
+			noBack:   true,
+			rc:       GoTypeComplete,
+			nerrs:    1,
+			ncorrect: 10,
+			mistakes: []mistake{{"hello", "hellp"}},
+		},
+		{
+			name:     "alt+backspace",
+			text:     "hello world",
+			steps:    script(typeKeys("hello wpr"), press(tcell.KeyBackspace2, tcell.ModAlt), typeKeys("world")),
+			rc:       GoTypeComplete,
+			ncorrect: 11,
+		},
+		{
+			name:     "ctrl+backspace",
+			text:     "hello world",
+			steps:    script(typeKeys("hello wpr"), press(tcell.KeyBackspace2, tcell.ModCtrl), typeKeys("world")),
+			rc:       GoTypeComplete,
+			ncorrect: 11,
+		},
+		{
+			name:     "ctrl+backspace on unix terminals",
+			text:     "hello world",
+			steps:    script(typeKeys("hello wpr"), press(tcell.KeyBackspace, tcell.ModNone), typeKeys("world")),
+			unixOnly: true,
+			rc:       GoTypeComplete,
+			ncorrect: 11,
+		},
+		{
+			name:     "newline is skipped",
+			text:     "hello \nworld",
+			steps:    typeKeys("hello world"),
+			rc:       GoTypeComplete,
+			ncorrect: 11,
+		},
+		{
+			name:     "skip word before newline",
+			text:     "hello \nworld",
+			steps:    typeKeys("he world"),
+			rc:       GoTypeComplete,
+			nerrs:    3,
+			ncorrect: 8,
+			mistakes: []mistake{{"hello", "he___"}},
+		},
+		{
+			name:     "backspace over newline",
+			text:     "hello \nworld",
+			steps:    script(typeKeys("hello "), press(tcell.KeyBackspace2, tcell.ModNone), typeKeys(" world")),
+			rc:       GoTypeComplete,
+			ncorrect: 11,
+		},
+		{
+			name:     "ctrl+w over newline",
+			text:     "hello \nworld",
+			steps:    script(typeKeys("hello wo"), press(tcell.KeyCtrlW, tcell.ModCtrl), press(tcell.KeyCtrlW, tcell.ModCtrl), typeKeys("hello world")),
+			rc:       GoTypeComplete,
+			ncorrect: 11,
+		},
+		{
+			name:  "resize",
+			text:  "hello world",
+			steps: script(typeKeys("hel"), []step{{tcell.NewEventResize(100, 30), keyInterval}}),
+			rc:    GoTypeResize,
+		},
+		{
+			name:  "escape",
+			text:  "hello world",
+			steps: script(typeKeys("hel"), press(tcell.KeyEscape, tcell.ModNone)),
+			rc:    GoTypeEscape,
+		},
+		{
+			name:  "ctrl+c",
+			text:  "hello world",
+			steps: script(typeKeys("hel"), press(tcell.KeyCtrlC, tcell.ModCtrl)),
+			rc:    GoTypeSigInt,
+		},
+		{
+			name:  "next",
+			text:  "hello world",
+			steps: press(tcell.KeyRight, tcell.ModNone),
+			rc:    GoTypeNext,
+		},
+		{
+			name:  "previous",
+			text:  "hello world",
+			steps: press(tcell.KeyLeft, tcell.ModNone),
+			rc:    GoTypePrevious,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.unixOnly && runtime.GOOS == "windows" {
+				t.Skip("ctrl+backspace is sent as a plain backspace on windows")
+			}
+
+			g := newTestGoType(tt.steps)
+			g.SkipWord = !tt.noSkip
+			g.DisableBackspace = tt.noBack
+
+			nerrs, ncorrect, rc, _, mistakes := g.play(tt.text, -1, false, "", &testStats{})
+
+			if rc != tt.rc {
+				t.Fatalf("rc = %d, want %d", rc, tt.rc)
+			}
+			if rc != GoTypeComplete {
+				return
+			}
+
+			if nerrs != tt.nerrs || ncorrect != tt.ncorrect {
+				t.Errorf("nerrs, ncorrect = %d, %d, want %d, %d", nerrs, ncorrect, tt.nerrs, tt.ncorrect)
+			}
+			if !reflect.DeepEqual(mistakes, tt.mistakes) {
+				t.Errorf("mistakes = %v, want %v", mistakes, tt.mistakes)
+			}
+		})
+	}
+}
+
+func TestPlayTimeout(t *testing.T) {
+	g := newTestGoType(script(typeKeys("hel"), wait(500*time.Millisecond), typeKeys("lo"), wait(2*time.Second), typeKeys(" world")))
+
+	nerrs, ncorrect, dur, rc, _, _ := g.StartTest([]segment{{Text: "hello world"}}, time.Second)
+
+	if rc != GoTypeComplete {
+		t.Fatalf("rc = %d, want %d", rc, GoTypeComplete)
+	}
+	if nerrs != 0 || ncorrect != 5 {
+		t.Errorf("nerrs, ncorrect = %d, %d, want 0, 5", nerrs, ncorrect)
+	}
+	if dur < time.Second {
+		t.Errorf("duration = %s, want at least 1s", dur)
+	}
+}
+
+func TestStartTestSegments(t *testing.T) {
+	g := newTestGoType(typeKeys("hello worldfoo bat"))
+
+	nerrs, ncorrect, dur, rc, mistakes, stats := g.StartTest([]segment{{Text: "hello world"}, {Text: "foo bar"}}, -1)
+
+	if rc != GoTypeComplete {
+		t.Fatalf("rc = %d, want %d", rc, GoTypeComplete)
+	}
+	if nerrs != 1 || ncorrect != 17 {
+		t.Errorf("nerrs, ncorrect = %d, %d, want 1, 17", nerrs, ncorrect)
+	}
+	if want := []mistake{{"bar", "bat"}}; !reflect.DeepEqual(mistakes, want) {
+		t.Errorf("mistakes = %v, want %v", mistakes, want)
+	}
+
+	// The clock only starts on the first key press
+	if want := 17 * keyInterval; dur != want {
+		t.Errorf("duration = %s, want %s", dur, want)
+	}
+	if stats.Keystrokes != 18 || stats.KeyErrors != 1 {
+		t.Errorf("keystrokes, errors = %d, %d, want 18, 1", stats.Keystrokes, stats.KeyErrors)
+	}
+	if len(stats.Samples) != 2 {
+		t.Errorf("got %d samples, want 2", len(stats.Samples))
+	}
+}
+
+func TestCorrectedErrors(t *testing.T) {
+	g := newTestGoType(script(typeKeys("hellp"), press(tcell.KeyBackspace2, tcell.ModNone), typeKeys("o wprl"), press(tcell.KeyCtrlW, tcell.ModCtrl), typeKeys("worle")))
+
+	_, _, _, rc, _, stats := g.StartTest([]segment{{Text: "hello world"}}, -1)
+
+	if rc != GoTypeComplete {
+		t.Fatalf("rc = %d, want %d", rc, GoTypeComplete)
+	}
+	if stats.Corrected != 2 || stats.Uncorrected != 1 {
+		t.Errorf("corrected, uncorrected = %d, %d, want 2, 1", stats.Corrected, stats.Uncorrected)
+	}
+	if stats.Keystrokes != 16 || stats.KeyErrors != 3 {
+		t.Errorf("keystrokes, errors = %d, %d, want 16, 3", stats.Keystrokes, stats.KeyErrors)
+	}
+}
+
+func TestPlayStream(t *testing.T) {
+	g := newTestGoType(script(typeKeys("hello one two one"), wait(5*time.Second)))
+
+	streamed := 0
+	g.Stream = func() string {
+		streamed++
+		return "one two"
+	}
+
+	nerrs, ncorrect, dur, rc, _, _ := g.StartTest([]segment{{Text: "hello"}}, 3*time.Second)
+
+	if rc != GoTypeComplete {
+		t.Fatalf("rc = %d, want %d", rc, GoTypeComplete)
+	}
+	if nerrs != 0 || ncorrect != 17 {
+		t.Errorf("nerrs, ncorrect = %d, %d, want 0, 17", nerrs, ncorrect)
+	}
+	if dur != 3*time.Second {
+		t.Errorf("duration = %s, want the time limit", dur)
+	}
+
+	// Two lines ahead of the cursor at the start, and one more per line typed
+	if streamed != 4 {
+		t.Errorf("streamed %d times, want 4", streamed)
+	}
+}
+
+func TestPlayCode(t *testing.T) {
+	enter := press(tcell.KeyEnter, tcell.ModNone)
+
+	wide := "x := " + strings.Repeat("a", 90) + strings.Repeat("b", 20)
+
+	tests := []struct {
+		name       string
+		code       string // the file typed, a short if statement when empty
+		steps      []step
+		typeIndent bool
+		rc         int
+		nerrs      int
+		ncorrect   int
+		keystrokes int
+		cursor     rune // when set, the character the cursor is left on, which has to be on screen
+	}{
+		{
+			name:       "indent skipped",
+			steps:      script(typeKeys("if x {"), enter, typeKeys("return"), enter, typeKeys("}")),
+			ncorrect:   15,
+			keystrokes: 15,
+		},
+		{
+			name:       "indent typed",
+			steps:      script(typeKeys("if x {"), enter, press(tcell.KeyTab, tcell.ModNone), typeKeys("return"), enter, typeKeys("}")),
+			typeIndent: true,
+			ncorrect:   19,
+			keystrokes: 19,
+		},
+		{
+			name:       "space for enter",
+			steps:      script(typeKeys("if x { return"), enter, typeKeys("}")),
+			nerrs:      1,
+			ncorrect:   14,
+			keystrokes: 15,
+		},
+		{
+			// The line is wider than the screen, it scrolls to keep the cursor in view
+			name:       "wide line",
+			code:       wide + "\n}\n",
+			steps:      script(typeKeys(wide[:95])),
+			rc:         GoTypeSigInt,
+			keystrokes: 95,
+			cursor:     'b',
+		},
+		{
+			name:       "wide line typed",
+			code:       wide + "\n}\n",
+			steps:      script(typeKeys(wide), enter, typeKeys("}")),
+			ncorrect:   len(wide) + 2,
+			keystrokes: len(wide) + 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := tt.code
+			if code == "" {
+				code = "if x {\n\treturn  \n}\n"
+			}
+
+			file := filepath.Join(t.TempDir(), "x.go")
+			if err := os.WriteFile(file, []byte(code), 0644); err != nil {
+				t.Fatal(err)
+			}
+			src, err := newSource("code:"+file, sourceOptions{TabWidth: 4})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			g := newTestGoType(tt.steps)
+			g.Code = true
+			g.SkipWord = false
+			g.TypeIndent = tt.typeIndent
+			g.TabWidth = 4
+
+			text, _ := src.Next(context.Background(), g.scr, 0)
+			nerrs, ncorrect, _, rc, _, stats := g.StartTest([]segment{{Text: text[0].Text}}, -1)
+
+			if rc != tt.rc {
+				t.Fatalf("rc = %d, want %d", rc, tt.rc)
+			}
+			if nerrs != tt.nerrs || ncorrect != tt.ncorrect {
+				t.Errorf("nerrs, ncorrect = %d, %d, want %d, %d", nerrs, ncorrect, tt.nerrs, tt.ncorrect)
+			}
+			if stats.Keystrokes != tt.keystrokes {
+				t.Errorf("keystrokes = %d, want %d", stats.Keystrokes, tt.keystrokes)
+			}
+
+			if tt.cursor != 0 {
+				sw, sh := g.scr.Size()
+				x, y, _ := g.scr.(tcell.SimulationScreen).GetCursor()
+				if x < 0 || x >= sw || y < 0 || y >= sh {
+					t.Fatalf("cursor at %d, %d is off the %dx%d screen", x, y, sw, sh)
+				}
+				if r, _, _, _ := g.scr.GetContent(x, y); r != tt.cursor {
+					t.Errorf("cursor on %q, want %q", r, tt.cursor)
+				}
+			}
+		})
+	}
+}
+
+func TestZen(t *testing.T) {
+	g := newTestGoType(script(
+		typeKeys("hi there"), press(tcell.KeyEnter, tcell.ModNone),
+		typeKeys("yo"), press(tcell.KeyBackspace2, tcell.ModNone), typeKeys("u"),
+		press(tcell.KeyCtrlD, tcell.ModCtrl)))
+
+	dur, rc, stats := g.Zen(-1)
+
+	if rc != GoTypeComplete {
+		t.Fatalf("rc = %d, want %d", rc, GoTypeComplete)
+	}
+	if want := 13 * keyInterval; dur != want {
+		t.Errorf("duration = %s, want %s", dur, want)
+	}
+	if stats.Keystrokes != 12 || stats.WordChars != len("hi there\nyu") {
+		t.Errorf("keystrokes, chars = %d, %d, want 12, %d", stats.Keystrokes, stats.WordChars, len("hi there\nyu"))
+	}
+}
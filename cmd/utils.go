@@ -1,77 +1,14 @@
 package main
 
 import (
-	"bytes" // bytes包实现了操作[]byte的函数
-	"fmt"   // fmt包提供了I/O函数
-	"os"    // os包提供了操作系统函数
+	"fmt" // fmt包提供了I/O函数
+	"os"  // os包提供了操作系统函数
 	"regexp"
 	"strings"
 
 	"github.com/gdamore/tcell"
 )
 
-// Read the theme file of the given name with the following format:
-// bgcol: #260346
-// fgcol: #DADADA
-// hicol: #C9CCCD
-// hicol2: #ad363f
-// hicol3: #E74C3C
-// errcol: #C54133
-func readTheme(name string) map[string]string {
-	// Read the file
-	res, err := os.ReadFile(fmt.Sprintf("themes/%s.txt", name))
-	if err != nil {
-		panic(err)
-	}
-
-	// Parse the file
-	theme := make(map[string]string)
-	for _, line := range bytes.Split(res, []byte("\n")) {
-		parts := bytes.Split(line, []byte(": "))
-		if len(parts) != 2 {
-			continue
-		}
-		theme[string(parts[0])] = string(parts[1])
-	}
-
-	return theme
-}
-
-func newTcellColor(hex string) (tcell.Color, error) {
-	// Check if the color is a valid hex color
-	if len(hex) != 7 {
-		return 0, fmt.Errorf("invalid hex color")
-	}
-
-	// Check if the color starts with a #
-	if hex[0] != '#' {
-		return 0, fmt.Errorf("invalid hex color")
-	}
-
-	// Inline function to convert a byte to a number
-	toNumber := func(c byte) int32 {
-		if c > '9' { // 如果c大于'9'
-			if c >= 'a' { // 如果c大于等于'a'
-				return int32(c - 'a' + 10)
-			} else {
-				return int32(c - 'A' + 10)
-			}
-		} else {
-			return int32(c - '0')
-		}
-	}
-
-	// Convert the hex color to a tcell.Color
-	r := toNumber(hex[1])<<4 | toNumber(hex[2]) // hex[1]左移4位或hex[2]
-	g := toNumber(hex[3])<<4 | toNumber(hex[4]) //
-	b := toNumber(hex[5])<<4 | toNumber(hex[6]) //
-
-	// Example
-	// hex = #260346
-	// r = 2*16 + 6 = 38
-	return tcell.NewRGBColor(r, g, b), nil
-}
-
 func wordWrapBytes(s []byte, n int) {
 	sp := 0
 	sz := 0
@@ -104,7 +41,9 @@ func wordWrap(s string, n int) string {
 	return string(r)
 }
 
-func wrapText(text string, width int) string {
+// wrapText reflows text to lines of at most width characters, narrower if the
+// screen is too small.
+func wrapText(scr tcell.Screen, text string, width int) string {
 	reflow := func(s string) string {
 		sw, _ := scr.Size()
 
@@ -155,7 +94,7 @@ func extractMistypedWords(text []rune, typed []rune) (mistakes []mistake) {
 	f := false
 
 	for i := range text {
-		if text[i] == ' ' {
+		if text[i] == ' ' || text[i] == '\n' {
 			if f {
 				mistakes = append(mistakes, mistake{string(w), string(t)})
 			}
@@ -190,6 +129,44 @@ func extractMistypedWords(text []rune, typed []rune) (mistakes []mistake) {
 	return
 }
 
+// countWordChars counts the characters MonkeyType bases its wpm on: every
+// character of a correctly typed word, plus every correctly typed space or
+// line break. A partially typed last word counts if it is correct so far.
+// Characters skipped for the typist don't count. It also returns the raw
+// character count, every non-skipped character typed.
+func countWordChars(text []rune, typed []rune, skipped func(i int) bool) (wordChars, rawChars int) {
+	wordStart := 0
+	wordOk := true
+
+	for i := 0; i <= len(text); i++ {
+		if i == len(text) || text[i] == ' ' || text[i] == '\n' {
+			if wordOk {
+				wordChars += i - wordStart
+			}
+
+			if i < len(text) && !skipped(i) && typed[i] == text[i] {
+				wordChars++
+			}
+
+			wordStart = i + 1
+			wordOk = true
+			continue
+		}
+
+		if typed[i] != text[i] {
+			wordOk = false
+		}
+	}
+
+	for i := range text {
+		if !skipped(i) && typed[i] != 0 {
+			rawChars++
+		}
+	}
+
+	return
+}
+
 // drawString draws a string to the screen at the given position with the given style.
 func drawString(scr tcell.Screen, x, y int, s string, cursorIdx int, style tcell.Style) {
 	sx := x
@@ -234,26 +211,48 @@ func drawStringAtCenter(scr tcell.Screen, s string, style tcell.Style) {
 // 	writeValue(MISTAKE_DB, db)
 // }
 
-func showReport(scr tcell.Screen, cpm, wpm int, accuracy float64, attribution string, mistakes []mistake) {
+func showReport(scr tcell.Screen, res result, attribution string) {
 	mistakeStr := ""
 	if attribution != "" {
 		attribution = "\n\nAttribution: " + attribution
 	}
 
-	if len(mistakes) > 0 {
+	if len(res.Mistakes) > 0 {
 		mistakeStr = "\nMistakes:    "
-		for i, m := range mistakes {
+		for i, m := range res.Mistakes {
 			mistakeStr += m.Word
-			if i != len(mistakes)-1 {
+			if i != len(res.Mistakes)-1 {
 				mistakeStr += ", "
 			}
 		}
 	}
 
-	report := fmt.Sprintf("WPM:         %d\nCPM:         %d\nAccuracy:    %.2f%%%s%s", wpm, cpm, accuracy, mistakeStr, attribution)
+	detailStr := ""
+	if res.QuoteLength != "" {
+		detailStr = "\nLength:      " + res.QuoteLength
+	}
+	if res.Mode != "" && res.Mode != "zen" { // zen has no text, replays aren't recorded with a seed
+		detailStr += fmt.Sprintf("\nSeed:        %d", res.Seed)
+	}
+
+	report := fmt.Sprintf("WPM:         %d\nRaw:         %d\nCPM:         %d\nAccuracy:    %.2f%%\nConsistency: %.2f%%\nKeystrokes:  %d\nErrors:      %d corrected, %d uncorrected\nTime:        %.1fs%s%s%s",
+		res.Wpm, res.RawWpm, res.Cpm, res.Accuracy, res.Consistency, res.Keystrokes, res.Corrected, res.Uncorrected, res.Duration, detailStr, mistakeStr, attribution)
+
+	// Stack the report above the graph, centering both
+	sw, sh := scr.Size()
+	nc, nr := calcStringDimensions(report)
+	gw, gh := graphDimensions(res.Wpms, sw-4)
+	if gh > 0 {
+		gh++ // gap between the report and the graph
+	}
+
+	y := (sh - nr - gh) / 2
 
 	scr.Clear()
-	drawStringAtCenter(scr, report, tcell.StyleDefault)
+	drawString(scr, (sw-nc)/2, y, report, -1, tcell.StyleDefault)
+	if gh > 0 {
+		drawGraph(scr, (sw-gw)/2, y+nr+1, gw, gh-1, res.Wpms)
+	}
 	scr.HideCursor()
 	scr.Show()
 
@@ -0,0 +1,197 @@
+// Zen mode, free typing with no target text
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gdamore/tcell"
+)
+
+const (
+	zenLines = 5  // 显示的行数, number of lines of typed text shown
+	zenWidth = 80 // 行宽, maximum line width
+)
+
+// zenLayout breaks typed into lines at newlines and at width characters,
+// returning the lines and the position of the character after the last one.
+func zenLayout(typed []rune, width int) (lines [][]rune, cx, cy int) {
+	line := []rune{}
+
+	for _, c := range typed {
+		if c == '\n' {
+			lines = append(lines, line)
+			line = []rune{}
+			continue
+		}
+
+		if len(line) == width {
+			lines = append(lines, line)
+			line = []rune{}
+		}
+
+		line = append(line, c)
+	}
+
+	lines = append(lines, line)
+	return lines, len(line), len(lines) - 1
+}
+
+// Zen runs a test without a target text. Whatever is typed is shown and timed
+// until ctrl+d is pressed, or the time limit runs out, and every character
+// counts as correct.
+func (t *gotype) Zen(timeLimit time.Duration) (duration time.Duration, rc int, stats testStats) {
+	var startTime time.Time
+	var typed []rune
+
+	if !t.BlockCursor {
+		t.tty.Write([]byte("\033[5 q"))
+		defer t.tty.Write([]byte("\033[2 q"))
+	}
+
+	t.scr.SetStyle(t.defaultStyle)
+
+	defer func() {
+		stats.Samples = stats.smp.finish()
+	}()
+
+	finish := func() {
+		rc = GoTypeComplete
+		duration = t.Now().Sub(startTime)
+		if timeLimit != -1 && duration > timeLimit {
+			duration = timeLimit
+		}
+
+		stats.WordChars = len(typed)
+		stats.RawChars = len(typed)
+		stats.smp.sample(duration, len(typed))
+		stats.smp.elapsed = duration
+		stats.smp.wordChars = len(typed)
+	}
+
+	redraw := func() {
+		sw, sh := t.scr.Size()
+		width := zenWidth
+		if width > sw-8 {
+			width = sw - 8
+		}
+		if width < 1 {
+			width = 1
+		}
+
+		lines, cx, cy := zenLayout(typed, width)
+		top := 0
+		if len(lines) > zenLines {
+			top = len(lines) - zenLines
+		}
+
+		x := (sw - width) / 2
+		y := (sh - zenLines) / 2
+
+		t.scr.Clear()
+		for i, line := range lines[top:] {
+			drawString(t.scr, x, y+i, string(line), -1, t.correctStyle)
+		}
+		t.scr.ShowCursor(x+cx, y+cy-top)
+
+		hint := "zen mode, ctrl+d to finish"
+		if startTime.IsZero() {
+			hint = "zen mode, start typing anything, ctrl+d to finish"
+		}
+		drawString(t.scr, x+(width-len(hint))/2, y+zenLines+1, hint, -1, t.defaultStyle)
+
+		if !startTime.IsZero() {
+			elapsed := t.Now().Sub(startTime)
+			status := fmt.Sprintf("%ds", int(elapsed/time.Second))
+			if timeLimit != -1 {
+				status = strconv.Itoa(int((timeLimit-elapsed)/time.Second) + 1)
+			}
+			if t.ShowWpm && elapsed > 1e7 {
+				status += fmt.Sprintf("  WPM: %d", int(float64(len(typed))/5/(float64(elapsed)/60e9)))
+			}
+			drawString(t.scr, x+(width-len(status))/2, y-2, status, -1, t.defaultStyle)
+		}
+
+		t.scr.Show()
+	}
+
+	deleteWord := func() {
+		for len(typed) > 0 && (typed[len(typed)-1] == ' ' || typed[len(typed)-1] == '\n') {
+			typed = typed[:len(typed)-1]
+		}
+
+		for len(typed) > 0 && typed[len(typed)-1] != ' ' && typed[len(typed)-1] != '\n' {
+			typed = typed[:len(typed)-1]
+		}
+	}
+
+	keystroke := func(c rune) {
+		if startTime.IsZero() {
+			startTime = t.Now()
+		}
+
+		stats.Keystrokes++
+		stats.smp.nkeys++
+		typed = append(typed, c)
+	}
+
+	stopTicker := t.startTicker()
+	defer stopTicker()
+
+	for {
+		redraw()
+
+		ev := t.Events.PollEvent()
+		if !startTime.IsZero() {
+			stats.smp.sample(t.Now().Sub(startTime), len(typed))
+		}
+
+		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			t.scr.Sync()
+		case *tcell.EventKey:
+			if isDeleteWord(ev) {
+				if !t.DisableBackspace {
+					deleteWord()
+				}
+				continue
+			}
+
+			switch ev.Key() {
+			case tcell.KeyCtrlC:
+				rc = GoTypeSigInt
+				return
+			case tcell.KeyEscape:
+				rc = GoTypeEscape
+				return
+			case tcell.KeyCtrlD:
+				if startTime.IsZero() {
+					rc = GoTypeEscape
+					return
+				}
+
+				finish()
+				return
+			case tcell.KeyCtrlL:
+				t.scr.Sync()
+			case tcell.KeyBackspace, tcell.KeyBackspace2:
+				if !t.DisableBackspace && len(typed) > 0 {
+					typed = typed[:len(typed)-1]
+				}
+			case tcell.KeyEnter:
+				keystroke('\n')
+			case tcell.KeyTab:
+				keystroke(' ')
+			case tcell.KeyRune:
+				keystroke(ev.Rune())
+			}
+		default: //tick
+			if timeLimit != -1 && !startTime.IsZero() && timeLimit <= t.Now().Sub(startTime) {
+				finish()
+				return
+			}
+		}
+	}
+}
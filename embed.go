@@ -0,0 +1,10 @@
+// Package gotype holds the word lists, quotes and themes bundled with gotype,
+// so the binary works wherever it is run from.
+package gotype
+
+import "embed"
+
+// Files are the data/ and themes/ directories as they were at build time
+//
+//go:embed data themes
+var Files embed.FS
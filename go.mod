@@ -2,14 +2,17 @@ module gotype
 
 go 1.22.2
 
+require (
+	github.com/gdamore/tcell v1.4.0
+	github.com/xyproto/ollamaclient v1.9.3
+)
+
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/gdamore/encoding v1.0.0 // indirect
-	github.com/gdamore/tcell v1.4.0 // indirect
 	github.com/lucasb-eyer/go-colorful v1.0.3 // indirect
 	github.com/mattn/go-runewidth v0.0.7 // indirect
 	github.com/xyproto/env/v2 v2.2.5 // indirect
-	github.com/xyproto/ollamaclient v1.9.3 // indirect
 	golang.org/x/sys v0.17.0 // indirect
 	golang.org/x/text v0.3.0 // indirect
 )